@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+const (
+	AuthMethodAgent       = "agent"
+	AuthMethodKey         = "key"
+	AuthMethodCertificate = "certificate"
+)
+
+type AuthConfig struct {
+	Agent          bool   `yaml:"agent"`
+	KeyFile        string `yaml:"key_file"`
+	PassphraseEnv  string `yaml:"passphrase_env"`
+	PassphraseFile string `yaml:"passphrase_file"`
+	Certificate    string `yaml:"certificate"`
+}
+
+type AuthMethodError struct {
+	Method string
+	Err    error
+}
+
+// AuthError collects the reasons every configured authentication method
+// failed, so that SSHError explains all of them rather than just the last one.
+type AuthError struct {
+	Err     error
+	Methods []AuthMethodError
+}
+
+func (e *AuthError) Error() string {
+	sb := strings.Builder{}
+	sb.WriteString(e.Err.Error())
+	for _, m := range e.Methods {
+		sb.WriteString(fmt.Sprintf("; %s: %v", m.Method, m.Err))
+	}
+	return sb.String()
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// sshAuth holds the prepared authentication for a single connection. The
+// agent connection, if any, must stay open until the handshake is done.
+type sshAuth struct {
+	signers []ssh.Signer
+	tried   []string
+	errors  []AuthMethodError
+	agent   net.Conn
+}
+
+func (a *sshAuth) Close() {
+	if a.agent != nil {
+		a.agent.Close()
+	}
+}
+
+func (a *sshAuth) Methods() []ssh.AuthMethod {
+	if len(a.signers) == 0 {
+		return nil
+	}
+	// The client only tries the publickey method once, so every signer has
+	// to be offered through the same callback.
+	return []ssh.AuthMethod{ssh.PublicKeys(a.signers...)}
+}
+
+// Wrap annotates a handshake failure with the per-method details.
+func (a *sshAuth) Wrap(err error) error {
+	if err == nil || !strings.Contains(err.Error(), "unable to authenticate") {
+		return err
+	}
+	methods := append([]AuthMethodError{}, a.errors...)
+	for _, m := range a.tried {
+		methods = append(methods, AuthMethodError{Method: m, Err: errors.New("rejected by server")})
+	}
+	return &AuthError{Err: err, Methods: methods}
+}
+
+func (c *AuthConfig) Prepare(idFile string) (*sshAuth, error) {
+	auth := &sshAuth{}
+	keyFile := c.KeyFile
+	if keyFile == "" {
+		keyFile = idFile
+	}
+
+	if keyFile != "" {
+		signer, err := c.loadKey(keyFile)
+		if err != nil {
+			auth.errors = append(auth.errors, AuthMethodError{Method: AuthMethodKey, Err: err})
+		} else {
+			if c.Certificate != "" {
+				certSigner, err := loadCertificate(c.Certificate, signer)
+				if err != nil {
+					auth.errors = append(auth.errors, AuthMethodError{Method: AuthMethodCertificate, Err: err})
+				} else {
+					auth.signers = append(auth.signers, certSigner)
+					auth.tried = append(auth.tried, AuthMethodCertificate)
+				}
+			}
+			auth.signers = append(auth.signers, signer)
+			auth.tried = append(auth.tried, AuthMethodKey)
+		}
+	} else if c.Certificate != "" {
+		auth.errors = append(auth.errors, AuthMethodError{Method: AuthMethodCertificate, Err: errors.New("certificate requires a key_file")})
+	}
+
+	if c.Agent {
+		signers, conn, err := agentSigners()
+		if err != nil {
+			auth.errors = append(auth.errors, AuthMethodError{Method: AuthMethodAgent, Err: err})
+		} else {
+			auth.agent = conn
+			auth.signers = append(auth.signers, signers...)
+			auth.tried = append(auth.tried, AuthMethodAgent)
+		}
+	}
+
+	if len(auth.signers) == 0 {
+		auth.Close()
+		if len(auth.errors) == 0 {
+			return nil, errors.New("no authentication method configured")
+		}
+		return nil, &AuthError{Err: errors.New("no usable authentication method"), Methods: auth.errors}
+	}
+	return auth, nil
+}
+
+func (c *AuthConfig) passphrase() ([]byte, error) {
+	if c.PassphraseEnv != "" {
+		value, ok := os.LookupEnv(c.PassphraseEnv)
+		if !ok {
+			return nil, fmt.Errorf("passphrase environment variable %s is not set", c.PassphraseEnv)
+		}
+		return []byte(value), nil
+	}
+	if c.PassphraseFile != "" {
+		data, err := os.ReadFile(c.PassphraseFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read passphrase file: %v", err)
+		}
+		return []byte(strings.TrimRight(string(data), "\r\n")), nil
+	}
+	return nil, errors.New("private key is encrypted but no passphrase_env or passphrase_file is configured")
+}
+
+func (c *AuthConfig) loadKey(keyFile string) (ssh.Signer, error) {
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read private key: %v", err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(key)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		passphrase, err := c.passphrase()
+		if err != nil {
+			return nil, err
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt private key: %v", err)
+		}
+		return signer, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse private key: %v", err)
+	}
+	return signer, nil
+}
+
+func loadCertificate(certFile string, signer ssh.Signer) (ssh.Signer, error) {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read certificate: %v", err)
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse certificate: %v", err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an OpenSSH certificate", certFile)
+	}
+	if cert.CertType != ssh.UserCert {
+		return nil, fmt.Errorf("%s is not a user certificate", certFile)
+	}
+	now := uint64(time.Now().Unix())
+	if cert.ValidBefore != ssh.CertTimeInfinity && now >= cert.ValidBefore {
+		return nil, fmt.Errorf("certificate expired at %s", time.Unix(int64(cert.ValidBefore), 0).Format(time.RFC3339))
+	}
+	if now < cert.ValidAfter {
+		return nil, fmt.Errorf("certificate is not valid until %s", time.Unix(int64(cert.ValidAfter), 0).Format(time.RFC3339))
+	}
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("certificate does not match private key: %v", err)
+	}
+	return certSigner, nil
+}
+
+func agentSigners() ([]ssh.Signer, net.Conn, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, nil, errors.New("SSH_AUTH_SOCK is not set")
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to connect to agent: %v", err)
+	}
+	signers, err := agent.NewClient(conn).Signers()
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("unable to list agent identities: %v", err)
+	}
+	if len(signers) == 0 {
+		conn.Close()
+		return nil, nil, errors.New("agent has no identities")
+	}
+	return signers, conn, nil
+}
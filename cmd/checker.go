@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/yaml.v3"
+)
+
+// Runner runs commands on the node being checked and returns their stdout.
+type Runner interface {
+	Run(ctx context.Context, cmd string) ([]byte, error)
+}
+
+type sshRunner struct {
+	client *ssh.Client
+}
+
+func (r sshRunner) Run(ctx context.Context, cmd string) ([]byte, error) {
+	return runCommand(ctx, r.client, cmd)
+}
+
+// CheckEnv is everything a check may use while it runs.
+type CheckEnv struct {
+	Node         *MonitoringConfig
+	Runner       Runner
+	Connectivity ConnectivityConfig
+	State        *StateStore
+}
+
+// Checker is a single check that can be enabled and configured per node.
+type Checker interface {
+	Name() string
+	// Options returns a pointer to a new options struct, which is also the
+	// schema of the check's config, or nil if the check takes no options.
+	Options() any
+	// Run returns the check's result, which is published under checks.<name>.
+	Run(ctx context.Context, env *CheckEnv, opts any) (any, error)
+}
+
+// legacyChecker is implemented by checks that also fill the top-level
+// fields of MonitoringResult, which existing dashboards depend on.
+type legacyChecker interface {
+	setLegacy(r *MonitoringResult, value any, err *CheckError)
+}
+
+type registeredChecker struct {
+	checker Checker
+	enabled bool
+}
+
+// checkers lists every known check in the order they run.
+var checkers []registeredChecker
+
+// RegisterChecker adds a check. Checks enabled by default run on every node
+// that does not disable them, others only where they are enabled in config.
+func RegisterChecker(checker Checker, enabledByDefault bool) {
+	if lookupChecker(checker.Name()) != nil {
+		panic(fmt.Sprintf("check %s registered twice", checker.Name()))
+	}
+	checkers = append(checkers, registeredChecker{checker: checker, enabled: enabledByDefault})
+}
+
+func lookupChecker(name string) Checker {
+	for _, r := range checkers {
+		if r.checker.Name() == name {
+			return r.checker
+		}
+	}
+	return nil
+}
+
+func checkerNames() []string {
+	names := make([]string, 0, len(checkers))
+	for _, r := range checkers {
+		names = append(names, r.checker.Name())
+	}
+	return names
+}
+
+// newOptions returns the default options of checker.
+func newOptions(checker Checker) any {
+	opts := checker.Options()
+	if opts != nil {
+		applyDefaults(reflect.ValueOf(opts))
+	}
+	return opts
+}
+
+// CheckSpec enables or disables a check, written either as a bool or as a
+// map of options, which implies the check is enabled. The zero value is an
+// enabled check, since yaml does not call UnmarshalYAML for a bare key.
+type CheckSpec struct {
+	Disabled bool
+	Raw      *yaml.Node
+	Options  any
+}
+
+func (s *CheckSpec) UnmarshalYAML(node *yaml.Node) error {
+	switch {
+	case node.Kind == yaml.ScalarNode && node.Tag == "!!bool":
+		var enabled bool
+		if err := node.Decode(&enabled); err != nil {
+			return err
+		}
+		s.Disabled = !enabled
+	case node.Kind == yaml.MappingNode:
+		s.Raw = node
+	default:
+		return &yaml.TypeError{Errors: []string{
+			fmt.Sprintf("line %d: check must be true, false or a map of options", node.Line),
+		}}
+	}
+	return nil
+}
+
+// mergeChecks layers check selections, later ones win. Options are merged
+// key by key, so a layer only needs to set the options it changes.
+func mergeChecks(layers ...map[string]CheckSpec) map[string]CheckSpec {
+	merged := make(map[string]CheckSpec)
+	for _, layer := range layers {
+		for name, spec := range layer {
+			if prev, ok := merged[name]; ok {
+				if spec.Raw == nil {
+					spec.Raw = prev.Raw
+				} else if prev.Raw != nil {
+					spec.Raw = mergeOptionNodes(prev.Raw, spec.Raw)
+				}
+			}
+			merged[name] = spec
+		}
+	}
+	for name, spec := range merged {
+		checker := lookupChecker(name)
+		if checker == nil {
+			continue
+		}
+		// Every layer was validated on its own, so errors are not expected here.
+		spec.Options = newOptions(checker)
+		if spec.Raw != nil && spec.Options != nil {
+			spec.Raw.Decode(spec.Options)
+		}
+		merged[name] = spec
+	}
+	return merged
+}
+
+func mergeOptionNodes(base *yaml.Node, override *yaml.Node) *yaml.Node {
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: override.Line, Column: override.Column}
+	overridden := make(map[string]bool)
+	for i := 0; i+1 < len(override.Content); i += 2 {
+		overridden[override.Content[i].Value] = true
+	}
+	for i := 0; i+1 < len(base.Content); i += 2 {
+		if !overridden[base.Content[i].Value] {
+			merged.Content = append(merged.Content, base.Content[i], base.Content[i+1])
+		}
+	}
+	merged.Content = append(merged.Content, override.Content...)
+	return merged
+}
+
+type enabledCheck struct {
+	checker Checker
+	options any
+}
+
+// enabledChecks returns the checks to run on the node, in registry order.
+func (c *MonitoringConfig) enabledChecks() []enabledCheck {
+	var enabled []enabledCheck
+	for _, r := range checkers {
+		name := r.checker.Name()
+		spec, ok := c.Checks[name]
+		if !ok {
+			if r.enabled {
+				enabled = append(enabled, enabledCheck{checker: r.checker, options: newOptions(r.checker)})
+			}
+			continue
+		}
+		if spec.Disabled {
+			continue
+		}
+		options := spec.Options
+		if options == nil {
+			options = newOptions(r.checker)
+		}
+		enabled = append(enabled, enabledCheck{checker: r.checker, options: options})
+	}
+	return enabled
+}
+
+// matchAny reports whether name matches any of the shell patterns.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	StatusOK       = "ok"
+	StatusWarning  = "warning"
+	StatusCritical = "critical"
+)
+
+// Thresholds are percentages at which a value becomes a warning or
+// critical. A zero threshold is never reached.
+type Thresholds struct {
+	Warning  float64 `yaml:"warning" default:"80"`
+	Critical float64 `yaml:"critical" default:"90"`
+}
+
+func (t Thresholds) Validate() error {
+	if t.Warning < 0 || t.Warning > 100 || t.Critical < 0 || t.Critical > 100 {
+		return fmt.Errorf("thresholds must be between 0 and 100")
+	}
+	if t.Warning > 0 && t.Critical > 0 && t.Warning > t.Critical {
+		return fmt.Errorf("warning threshold %g is above critical threshold %g", t.Warning, t.Critical)
+	}
+	return nil
+}
+
+func (t Thresholds) Status(percent float64) string {
+	switch {
+	case t.Critical > 0 && percent >= t.Critical:
+		return StatusCritical
+	case t.Warning > 0 && percent >= t.Warning:
+		return StatusWarning
+	}
+	return StatusOK
+}
+
+// CheckResult is the outcome of a single check in MonitoringResult.Checks.
+type CheckResult struct {
+	Result   any         `json:"result,omitempty"`
+	Error    *CheckError `json:"error,omitempty"`
+	Duration float64     `json:"duration"`
+}
+
+// validateChecks decodes the options of every check in checks.
+func (v *configValidator) validateChecks(p []any, checks map[string]CheckSpec) {
+	for name, spec := range checks {
+		checker := lookupChecker(name)
+		if checker == nil {
+			v.addf(append(p, name), "unknown check %q, expected one of %s", name, strings.Join(checkerNames(), ", "))
+			continue
+		}
+		spec.Options = v.decodeOptions(append(p, name), checker, spec.Raw)
+		checks[name] = spec
+	}
+}
+
+func (v *configValidator) decodeOptions(p []any, checker Checker, raw *yaml.Node) any {
+	opts := newOptions(checker)
+	if raw == nil {
+		return opts
+	}
+	if opts == nil {
+		if len(raw.Content) > 0 {
+			v.addLine(raw.Line, p, "check %s takes no options", checker.Name())
+		}
+		return nil
+	}
+	v.checkKnownFields(p, raw, reflect.TypeOf(opts))
+	if err := raw.Decode(opts); err != nil {
+		if typeErr, ok := err.(*yaml.TypeError); ok {
+			v.addYAMLErrors(typeErr)
+		} else {
+			v.addLine(raw.Line, p, "%v", err)
+		}
+		return opts
+	}
+	if validated, ok := opts.(interface{ Validate() error }); ok {
+		if err := validated.Validate(); err != nil {
+			v.addLine(raw.Line, p, "%v", err)
+		}
+	}
+	return opts
+}
+
+// checkKnownFields reports mapping keys that have no field in t, the way
+// KnownFields does for the config file itself.
+func (v *configValidator) checkKnownFields(p []any, node *yaml.Node, t reflect.Type) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case node.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice:
+		for i, item := range node.Content {
+			v.checkKnownFields(append(p, i), item, t.Elem())
+		}
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Map:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			v.checkKnownFields(append(p, node.Content[i].Value), node.Content[i+1], t.Elem())
+		}
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Struct:
+		fields := make(map[string]reflect.Type)
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+			if name != "" && name != "-" {
+				fields[name] = t.Field(i).Type
+			}
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			field, ok := fields[key.Value]
+			if !ok {
+				v.addLine(key.Line, append(p, key.Value), "unknown option %q", key.Value)
+				continue
+			}
+			v.checkKnownFields(append(p, key.Value), node.Content[i+1], field)
+		}
+	}
+}
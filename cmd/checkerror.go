@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	ErrorCategoryConnect   = "connect"
+	ErrorCategoryAuth      = "auth"
+	ErrorCategoryHostKey   = "host_key"
+	ErrorCategoryTimeout   = "timeout"
+	ErrorCategoryCancelled = "cancelled"
+	ErrorCategoryCommand   = "command"
+	ErrorCategoryParse     = "parse"
+	ErrorCategoryFailed    = "failed"
+)
+
+// CheckError is the serializable form of an error in MonitoringResult.
+type CheckError struct {
+	Message  string `json:"message"`
+	Category string `json:"category"`
+	Command  string `json:"command,omitempty"`
+	ExitCode int    `json:"exit_code,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+	err      error
+}
+
+func (e *CheckError) Error() string {
+	return e.Message
+}
+
+func (e *CheckError) Unwrap() error {
+	return e.err
+}
+
+// Detail formats the error with everything known about it, for String().
+func (e *CheckError) Detail() string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("[%s] %s", e.Category, e.Message))
+	if e.ExitCode != 0 {
+		sb.WriteString(fmt.Sprintf(" (exit code %d)", e.ExitCode))
+	}
+	return sb.String()
+}
+
+// ParseError is returned when a command ran but its output made no sense.
+type ParseError struct {
+	Command string
+	Err     error
+}
+
+func (e *ParseError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func parseErrorf(command string, format string, args ...any) error {
+	return &ParseError{Command: command, Err: fmt.Errorf(format, args...)}
+}
+
+// FailedError is returned by a check that ran fine but found something
+// wrong, e.g. a process that is not running. Its result is still published.
+type FailedError struct {
+	Err error
+}
+
+func (e *FailedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FailedError) Unwrap() error {
+	return e.Err
+}
+
+func failedErrorf(format string, args ...any) error {
+	return &FailedError{Err: fmt.Errorf(format, args...)}
+}
+
+// newCheckError categorizes err. Errors that match no category get fallback.
+func newCheckError(err error, fallback string) *CheckError {
+	if err == nil {
+		return nil
+	}
+	var checkErr *CheckError
+	if errors.As(err, &checkErr) {
+		return checkErr
+	}
+	checkErr = &CheckError{
+		Message:  err.Error(),
+		Category: fallback,
+		err:      err,
+	}
+
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		checkErr.Command = cmdErr.Command
+		checkErr.ExitCode = cmdErr.ExitCode
+		checkErr.Stderr = cmdErr.Stderr
+	}
+	var parseErr *ParseError
+	var failedErr *FailedError
+	var timeoutErr *CheckTimeoutError
+	var mismatch *HostKeyMismatchError
+	var authErr *AuthError
+	switch {
+	case errors.As(err, &timeoutErr), errors.Is(err, context.DeadlineExceeded):
+		checkErr.Category = ErrorCategoryTimeout
+	case errors.Is(err, context.Canceled):
+		checkErr.Category = ErrorCategoryCancelled
+	case errors.As(err, &mismatch):
+		checkErr.Category = ErrorCategoryHostKey
+	case errors.As(err, &authErr):
+		checkErr.Category = ErrorCategoryAuth
+	case errors.As(err, &parseErr):
+		checkErr.Category = ErrorCategoryParse
+		checkErr.Command = parseErr.Command
+	case errors.As(err, &failedErr):
+		checkErr.Category = ErrorCategoryFailed
+	case cmdErr != nil:
+		checkErr.Category = ErrorCategoryCommand
+	}
+	return checkErr
+}
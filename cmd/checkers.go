@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+)
+
+const (
+	CheckHostName     = "hostname"
+	CheckUserName     = "user"
+	CheckDisk         = "disk"
+	CheckLogins       = "logins"
+	CheckConnectivity = "connectivity"
+)
+
+func init() {
+	RegisterChecker(hostNameCheck{}, true)
+	RegisterChecker(userNameCheck{}, true)
+	RegisterChecker(diskCheck{}, true)
+	RegisterChecker(loginsCheck{}, true)
+	RegisterChecker(connectivityCheck{}, true)
+}
+
+type hostNameCheck struct{}
+
+func (hostNameCheck) Name() string { return CheckHostName }
+func (hostNameCheck) Options() any { return nil }
+
+func (hostNameCheck) Run(ctx context.Context, env *CheckEnv, _ any) (any, error) {
+	return env.Node.getNodeName(ctx, env.Runner)
+}
+
+func (hostNameCheck) setLegacy(r *MonitoringResult, value any, err *CheckError) {
+	r.NodeName, _ = value.(string)
+	r.HostNameError = err
+}
+
+type userNameCheck struct{}
+
+func (userNameCheck) Name() string { return CheckUserName }
+func (userNameCheck) Options() any { return nil }
+
+func (userNameCheck) Run(ctx context.Context, env *CheckEnv, _ any) (any, error) {
+	return env.Node.getUserName(ctx, env.Runner)
+}
+
+func (userNameCheck) setLegacy(r *MonitoringResult, value any, err *CheckError) {
+	r.UserName, _ = value.(string)
+	r.UserNameError = err
+}
+
+type DiskInfo struct {
+	FreeSpace  int64   `json:"free_space"`
+	TotalSpace int64   `json:"total_space"`
+	DiskUsage  float64 `json:"disk_usage"`
+}
+
+type diskCheck struct{}
+
+func (diskCheck) Name() string { return CheckDisk }
+func (diskCheck) Options() any { return nil }
+
+func (diskCheck) Run(ctx context.Context, env *CheckEnv, _ any) (any, error) {
+	total, free, usage, err := env.Node.getDiskInfo(ctx, env.Runner)
+	if err != nil {
+		return nil, err
+	}
+	return &DiskInfo{FreeSpace: free, TotalSpace: total, DiskUsage: usage}, nil
+}
+
+func (diskCheck) setLegacy(r *MonitoringResult, value any, err *CheckError) {
+	if info, ok := value.(*DiskInfo); ok {
+		r.FreeSpace, r.TotalSpace, r.DiskUsage = info.FreeSpace, info.TotalSpace, info.DiskUsage
+	}
+	r.DiskInfoError = err
+}
+
+type loginsCheck struct{}
+
+func (loginsCheck) Name() string { return CheckLogins }
+func (loginsCheck) Options() any { return &loginsOptions{} }
+
+func (loginsCheck) Run(ctx context.Context, env *CheckEnv, opts any) (any, error) {
+	records, err := env.Node.getLoginRecords(ctx, env.Runner)
+	if err != nil {
+		return nil, err
+	}
+	opts.(*loginsOptions).mark(records)
+	markNewLogins(env, records)
+	return records, nil
+}
+
+func (loginsCheck) setLegacy(r *MonitoringResult, value any, err *CheckError) {
+	r.LoginRecords, _ = value.([]UserLoginRecord)
+	r.LoginRecordsError = err
+}
+
+type connectivityOptions struct {
+	ICMP bool `yaml:"icmp" default:"true"`
+	TCP  bool `yaml:"tcp" default:"true"`
+	HTTP bool `yaml:"http" default:"true"`
+	// Endpoints limits the probed endpoints to names matching these patterns.
+	Endpoints []string `yaml:"endpoints"`
+}
+
+func (o *connectivityOptions) probes(name string) bool {
+	return len(o.Endpoints) == 0 || matchAny(o.Endpoints, name)
+}
+
+type connectivityCheck struct{}
+
+func (connectivityCheck) Name() string { return CheckConnectivity }
+func (connectivityCheck) Options() any { return &connectivityOptions{} }
+
+func (connectivityCheck) Run(ctx context.Context, env *CheckEnv, opts any) (any, error) {
+	o := opts.(*connectivityOptions)
+	conn := env.Connectivity
+	var tcp []TCPEndpoint
+	var icmp []ICMPEndpoint
+	var http []HTTPEndpoint
+	for _, e := range conn.TCP {
+		if o.TCP && o.probes(e.Name) {
+			tcp = append(tcp, e)
+		}
+	}
+	for _, e := range conn.ICMP {
+		if o.ICMP && o.probes(e.Name) {
+			icmp = append(icmp, e)
+		}
+	}
+	for _, e := range conn.HTTP {
+		if o.HTTP && o.probes(e.Name) {
+			http = append(http, e)
+		}
+	}
+	return env.Node.getConnectivity(ctx, env.Runner, tcp, icmp, http)
+}
+
+func (connectivityCheck) setLegacy(r *MonitoringResult, value any, err *CheckError) {
+	r.Connectivity, _ = value.(map[string]ConnectivityStatus)
+	r.ConnectivityError = err
+}
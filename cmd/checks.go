@@ -1,14 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
-	"net"
+	"math"
 	"strconv"
 	"strings"
 	"time"
-
-	"golang.org/x/crypto/ssh"
 )
 
 type MonitoringResult struct {
@@ -26,15 +26,17 @@ type MonitoringResult struct {
 	CheckStartTime        time.Time                     `json:"check_start_time"`
 	CheckEndTime          time.Time                     `json:"check_end_time"`
 	CheckDuration         float64                       `json:"check_duration"`
-	SSHError              error                         `json:"ssh_error,omitempty"`
-	HostNameError         error                         `json:"host_name_error,omitempty"`
-	UserNameError         error                         `json:"user_name_error,omitempty"`
-	DiskInfoError         error                         `json:"disk_info_error,omitempty"`
-	LoginRecordsError     error                         `json:"login_records_error,omitempty"`
-	ConnectivityICMPError error                         `json:"connectivity_icmp_error,omitempty"`
-	ConnectivityTCPError  error                         `json:"connectivity_tcp_error,omitempty"`
-	ConnectivityHTTPError error                         `json:"connectivity_http_error,omitempty"`
-	ConnectivityError     error                         `json:"connectivity_error,omitempty"`
+	SSHError              *CheckError                   `json:"ssh_error,omitempty"`
+	SSHHostKeyError       *CheckError                   `json:"ssh_host_key_error,omitempty"`
+	HostNameError         *CheckError                   `json:"host_name_error,omitempty"`
+	UserNameError         *CheckError                   `json:"user_name_error,omitempty"`
+	DiskInfoError         *CheckError                   `json:"disk_info_error,omitempty"`
+	LoginRecordsError     *CheckError                   `json:"login_records_error,omitempty"`
+	ConnectivityICMPError *CheckError                   `json:"connectivity_icmp_error,omitempty"`
+	ConnectivityTCPError  *CheckError                   `json:"connectivity_tcp_error,omitempty"`
+	ConnectivityHTTPError *CheckError                   `json:"connectivity_http_error,omitempty"`
+	ConnectivityError     *CheckError                   `json:"connectivity_error,omitempty"`
+	Checks                map[string]CheckResult        `json:"checks,omitempty"`
 }
 
 type ConnectivityStatusICMP struct {
@@ -72,206 +74,169 @@ type UserLoginRecord struct {
 	IsRemote   bool      `json:"is_remote"`
 	IP         string    `json:"ip"`
 	Source     string    `json:"source"`
+	Terminal   string    `json:"terminal"`
 	LoginTime  time.Time `json:"login_time"`
 	LogoutTime time.Time `json:"logout_time"`
+	// New is set for sessions that were not in `last` on the previous run.
+	New bool `json:"is_new"`
+	// Suspicious is set for remote logins from outside the allowlist.
+	Suspicious bool `json:"is_suspicious"`
 }
 
-func (m *MonitoringConfig) getNodeName(client *ssh.Client) (string, error) {
-	session, err := client.NewSession()
-	if err != nil {
-		return "", fmt.Errorf("failed to create session: %v", err)
-	}
-	defer session.Close()
-
-	output, err := session.CombinedOutput("hostname")
+func (m *MonitoringConfig) getNodeName(ctx context.Context, runner Runner) (string, error) {
+	cmd := "hostname"
+	output, err := runner.Run(ctx, cmd)
 	if err != nil {
-		return "", fmt.Errorf("failed to execute hostname command: %v", err)
+		return "", fmt.Errorf("failed to execute hostname command: %w", err)
 	}
 	hostname := strings.TrimSpace(string(output))
 	if hostname == "" {
-		return "", fmt.Errorf("hostname command returned empty result")
+		return "", parseErrorf(cmd, "hostname command returned empty result")
 	}
 	return hostname, nil
 }
 
-func (m *MonitoringConfig) getUserName(client *ssh.Client) (string, error) {
-	session, err := client.NewSession()
-	if err != nil {
-		return "", fmt.Errorf("failed to create session: %v", err)
-	}
-	defer session.Close()
-
-	output, err := session.CombinedOutput("whoami")
+func (m *MonitoringConfig) getUserName(ctx context.Context, runner Runner) (string, error) {
+	cmd := "whoami"
+	output, err := runner.Run(ctx, cmd)
 	if err != nil {
-		return "", fmt.Errorf("failed to execute command: %v", err)
+		return "", fmt.Errorf("failed to execute command: %w", err)
 	}
 	username := strings.TrimSpace(string(output))
 	if username == "" {
-		return "", fmt.Errorf("whoami command returned empty result")
+		return "", parseErrorf(cmd, "whoami command returned empty result")
 	}
 	return username, nil
 }
 
-func (m *MonitoringConfig) getDiskInfo(client *ssh.Client) (int64, int64, float64, error) {
-	session, err := client.NewSession()
-	if err != nil {
-		return 0, 0, 0, fmt.Errorf("failed to create session: %v", err)
+// getDiskInfo returns total bytes, free bytes and usage percent of /.
+func (m *MonitoringConfig) getDiskInfo(ctx context.Context, runner Runner) (int64, int64, float64, error) {
+	rows, err := runDF(ctx, runner, "/")
+	if err == nil {
+		return rows[0].total, rows[0].free, rows[0].usage(), nil
+	}
+	if ctx.Err() != nil {
+		return 0, 0, 0, err
 	}
-	defer session.Close()
+	log.Printf("[%s] Falling back to human-readable df: %v", m.NodeName, err)
+	return m.getDiskInfoHumanReadable(ctx, runner)
+}
 
-	output, err := session.CombinedOutput("df -h / | awk 'NR==2 {print $2 \" \" $4 \" \" $5}'")
+// getDiskInfoHumanReadable parses `df -h`, for df builds that support neither
+// -B nor -T. Sizes are rounded by df, so they are approximate.
+func (m *MonitoringConfig) getDiskInfoHumanReadable(ctx context.Context, runner Runner) (int64, int64, float64, error) {
+	cmd := "LC_ALL=C df -P -h /"
+	output, err := runner.Run(ctx, cmd)
 	if err != nil {
-		return 0, 0, 0, fmt.Errorf("failed to execute command: %v", err)
+		return 0, 0, 0, fmt.Errorf("failed to execute command: %w", err)
 	}
 
-	fields := strings.Fields(string(output))
-	if len(fields) != 3 {
-		return 0, 0, 0, fmt.Errorf("unexpected output format: %s", string(output))
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) < 2 {
+		return 0, 0, 0, parseErrorf(cmd, "unexpected output format: %s", string(output))
+	}
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 5 {
+		return 0, 0, 0, parseErrorf(cmd, "unexpected output format: %s", string(output))
 	}
 
-	totalSpace, err := parseHumanReadableSize(fields[0])
+	totalSpace, err := parseHumanReadableSize(fields[1])
 	if err != nil {
-		return 0, 0, 0, fmt.Errorf("failed to parse total space: %v", err)
+		return 0, 0, 0, parseErrorf(cmd, "failed to parse total space: %v", err)
 	}
 
-	freeSpace, err := parseHumanReadableSize(fields[1])
+	freeSpace, err := parseHumanReadableSize(fields[3])
 	if err != nil {
-		return 0, 0, 0, fmt.Errorf("failed to parse free space: %v", err)
+		return 0, 0, 0, parseErrorf(cmd, "failed to parse free space: %v", err)
 	}
 
-	diskUsageStr := strings.TrimSuffix(fields[2], "%")
+	diskUsageStr := strings.TrimSuffix(fields[4], "%")
 	diskUsage, err := strconv.ParseFloat(diskUsageStr, 64)
 	if err != nil {
-		return 0, 0, 0, fmt.Errorf("failed to parse disk usage: %v", err)
+		return 0, 0, 0, parseErrorf(cmd, "failed to parse disk usage: %v", err)
 	}
 
 	return totalSpace, freeSpace, diskUsage, nil
 }
 
+var sizeUnits = map[byte]float64{
+	'K': 1 << 10,
+	'M': 1 << 20,
+	'G': 1 << 30,
+	'T': 1 << 40,
+	'P': 1 << 50,
+	'E': 1 << 60,
+}
+
+// parseHumanReadableSize parses sizes like 512, 3.5G, 3,5G (decimal comma
+// locales), 1.2Ti or 20MB. Units are powers of 1024, as printed by df -h.
 func parseHumanReadableSize(sizeStr string) (int64, error) {
-	multiplier := 1
-	if strings.HasSuffix(sizeStr, "K") {
-		multiplier = 1024
-	} else if strings.HasSuffix(sizeStr, "M") {
-		multiplier = 1024 * 1024
-	} else if strings.HasSuffix(sizeStr, "G") {
-		multiplier = 1024 * 1024 * 1024
-	} else if strings.HasSuffix(sizeStr, "T") {
-		multiplier = 1024 * 1024 * 1024 * 1024
-	} else if strings.HasSuffix(sizeStr, "P") {
-		multiplier = 1024 * 1024 * 1024 * 1024 * 1024
-	} else if strings.HasSuffix(sizeStr, "E") {
-		multiplier = 1024 * 1024 * 1024 * 1024 * 1024 * 1024
-	} else if !strings.HasSuffix(sizeStr, "B") {
+	s := strings.TrimSpace(sizeStr)
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "i")
+	s = strings.ReplaceAll(s, ",", ".")
+	if s == "" {
 		return 0, fmt.Errorf("unsupported size format: %s", sizeStr)
 	}
 
-	size, err := strconv.ParseInt(strings.TrimRight(sizeStr, "BKMGTPE"), 10, 64)
-	if err != nil {
-		return 0, fmt.Errorf("failed to parse size: %v", err)
+	multiplier := 1.0
+	if unit, ok := sizeUnits[strings.ToUpper(s[len(s)-1:])[0]]; ok {
+		multiplier = unit
+		s = s[:len(s)-1]
 	}
 
-	return size * int64(multiplier), nil
+	size, err := strconv.ParseFloat(s, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("unsupported size format: %s", sizeStr)
+	}
+	return int64(math.Round(size * multiplier)), nil
 }
 
-func (m *MonitoringConfig) getLoginRecords(client *ssh.Client) ([]UserLoginRecord, error) {
-	session, err := client.NewSession()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create session: %v", err)
+// getLoginRecords reads sessions from `last`, newest first, and falls back
+// to dumping wtmp where last has no --time-format, then to BusyBox last,
+// which has neither utmpdump nor exact times. -i prints the IP rather than
+// the host name, which the allowlist can check.
+func (m *MonitoringConfig) getLoginRecords(ctx context.Context, runner Runner) ([]UserLoginRecord, error) {
+	cmd := "LC_ALL=C last -w -i --time-format=iso"
+	output, lastErr := runner.Run(ctx, cmd)
+	if lastErr == nil {
+		return parseLast(m.NodeName, cmd, output, time.Now())
 	}
-	defer session.Close()
-
-	output, err := session.CombinedOutput("last --time-format=iso")
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute command: %v", err)
+	lastErr = fmt.Errorf("failed to execute command: %w", lastErr)
+	if ctx.Err() != nil {
+		return nil, lastErr
 	}
+	log.Printf("[%s] Unable to read logins with last, trying utmpdump: %v", m.NodeName, lastErr)
 
-	lines := strings.Split(string(output), "\n")
-	var records []UserLoginRecord
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "wtmp begins") {
-			continue
-		}
-
-		fields := strings.Fields(line)
-		if len(fields) < 4 {
-			continue
-		}
-
-		userName := fields[0]
-		terminal := fields[1]
-		src := fields[2]
-		isRemote := true
-		ip := ""
-		if parsedIP := net.ParseIP(src); parsedIP != nil {
-			ip = parsedIP.String()
-		} else if strings.HasPrefix(src, "[") && strings.HasSuffix(src, "]") {
-			if parsedIP := net.ParseIP(strings.Trim(src, "[]")); parsedIP != nil {
-				ip = parsedIP.String()
-			}
-		}
-		if ip == "" {
-			isRemote = false
-		}
-
-		if userName == "reboot" || strings.Contains(terminal, "system") {
-			continue
-		}
-
-		loginTime, _ := time.Parse("2006-01-02T15:04:05-07:00", fields[3])
-		logoutTime, err := time.Parse("2006-01-02T15:04:05-07:00", fields[5])
-		if err != nil {
-			if len(fields) >= 7 && fields[5] == "down" {
-				timeDiff := fields[6] //  (00:04) or (94+18:58)
-				timeDiffParts := strings.Split(timeDiff, "+")
-				days := 0
-				hoursMinutes := timeDiffParts[len(timeDiffParts)-1]
-				timeParts := strings.Split(hoursMinutes, ":")
-				hours, _ := strconv.Atoi(timeParts[0])
-				minutes, _ := strconv.Atoi(timeParts[1])
-				if len(timeDiffParts) > 1 {
-					days, _ = strconv.Atoi(timeDiffParts[0])
-				}
-				logoutTime = loginTime.Add(
-					time.Duration(days)*24*time.Hour +
-						time.Duration(hours)*time.Hour +
-						time.Duration(minutes)*time.Minute,
-				)
-			}
-		}
-
-		record := UserLoginRecord{
-			UserName:   userName,
-			IP:         ip,
-			IsRemote:   isRemote,
-			Source:     src,
-			LoginTime:  loginTime,
-			LogoutTime: logoutTime,
-			Active:     false,
-		}
-
-		record.Active = len(fields) >= 5 && (fields[4] == "still" || fields[4] == "gone")
-
-		records = append(records, record)
+	dumpCmd := "LC_ALL=C TZ=UTC0 utmpdump /var/log/wtmp"
+	output, err := runner.Run(ctx, dumpCmd)
+	if err == nil {
+		return parseUtmpdump(dumpCmd, output)
+	}
+	dumpErr := fmt.Errorf("failed to execute command: %w", err)
+	if ctx.Err() != nil {
+		return nil, errors.Join(lastErr, dumpErr)
 	}
+	log.Printf("[%s] Unable to dump wtmp, trying BusyBox last: %v", m.NodeName, dumpErr)
 
-	return records, nil
+	// -W keeps IPv6 addresses from being cut to 16 characters.
+	busyBoxCmd := "LC_ALL=C TZ=UTC0 last -W"
+	output, err = runner.Run(ctx, busyBoxCmd)
+	if err != nil {
+		return nil, errors.Join(lastErr, dumpErr, fmt.Errorf("failed to execute command: %w", err))
+	}
+	return parseLast(m.NodeName, busyBoxCmd, output, time.Now())
 }
 
-func (m *MonitoringConfig) getConnectivityICMP(client *ssh.Client, endpoints []ICMPEndpoint) ([]ConnectivityStatusICMP, error) {
+func (m *MonitoringConfig) getConnectivityICMP(ctx context.Context, runner Runner, endpoints []ICMPEndpoint) ([]ConnectivityStatusICMP, error) {
 	statuses := []ConnectivityStatusICMP{}
 
 	for _, endpoint := range endpoints {
-		log.Printf("[%s] Getting ICMP connectivity for %s", m.NodeName, endpoint.Name)
-		session, err := client.NewSession()
-		if err != nil {
-			return nil, fmt.Errorf("failed to create session: %v", err)
+		if err := ctx.Err(); err != nil {
+			return statuses, fmt.Errorf("connectivity checks cancelled: %w", err)
 		}
-		defer session.Close()
+		log.Printf("[%s] Getting ICMP connectivity for %s", m.NodeName, endpoint.Name)
 
-		rawPingData, err := session.CombinedOutput(fmt.Sprintf("ping -c 5 -W 1 %s", endpoint.Address))
+		rawPingData, err := runner.Run(ctx, fmt.Sprintf("ping -c 5 -W 1 %s", endpoint.Address))
 		if err != nil {
 			log.Printf("Failed to execute command: %v", err)
 			statuses = append(statuses, ConnectivityStatusICMP{
@@ -334,19 +299,17 @@ func (m *MonitoringConfig) getConnectivityICMP(client *ssh.Client, endpoints []I
 	return statuses, nil
 }
 
-func (m *MonitoringConfig) getConnectivityTCP(client *ssh.Client, endpoints []TCPEndpoint) ([]ConnectivityStatusTCP, error) {
+func (m *MonitoringConfig) getConnectivityTCP(ctx context.Context, runner Runner, endpoints []TCPEndpoint) ([]ConnectivityStatusTCP, error) {
 	statuses := []ConnectivityStatusTCP{}
 
 	for _, endpoint := range endpoints {
-		log.Printf("[%s] Getting ICMP connectivity for %s", m.NodeName, endpoint.Name)
-		session, err := client.NewSession()
-		if err != nil {
-			return nil, fmt.Errorf("failed to create session: %v", err)
+		if err := ctx.Err(); err != nil {
+			return statuses, fmt.Errorf("connectivity checks cancelled: %w", err)
 		}
-		defer session.Close()
+		log.Printf("[%s] Getting ICMP connectivity for %s", m.NodeName, endpoint.Name)
 
 		cmd := fmt.Sprintf("timeout 3 bash -c '</dev/tcp/%s/%d' && echo 'true' || echo 'false'", endpoint.Address, endpoint.Port)
-		output, err := session.CombinedOutput(cmd)
+		output, err := runner.Run(ctx, cmd)
 
 		if err == nil && strings.TrimSpace(string(output)) == "true" {
 			statuses = append(statuses, ConnectivityStatusTCP{
@@ -368,16 +331,14 @@ func (m *MonitoringConfig) getConnectivityTCP(client *ssh.Client, endpoints []TC
 	return statuses, nil
 }
 
-func (m *MonitoringConfig) getConnectivityHTTP(client *ssh.Client, endpoints []HTTPEndpoint) ([]ConnectivityStatusHTTP, error) {
+func (m *MonitoringConfig) getConnectivityHTTP(ctx context.Context, runner Runner, endpoints []HTTPEndpoint) ([]ConnectivityStatusHTTP, error) {
 	statuses := []ConnectivityStatusHTTP{}
 
 	for _, endpoint := range endpoints {
-		log.Printf("[%s] Getting TCP connectivity for %s", m.NodeName, endpoint.Name)
-		session, err := client.NewSession()
-		if err != nil {
-			return nil, fmt.Errorf("failed to create session: %v", err)
+		if err := ctx.Err(); err != nil {
+			return statuses, fmt.Errorf("connectivity checks cancelled: %w", err)
 		}
-		defer session.Close()
+		log.Printf("[%s] Getting TCP connectivity for %s", m.NodeName, endpoint.Name)
 		currentStatus := ConnectivityStatusHTTP{
 			Name:   endpoint.Name,
 			Host:   endpoint.Address,
@@ -386,7 +347,7 @@ func (m *MonitoringConfig) getConnectivityHTTP(client *ssh.Client, endpoints []H
 			Error:  "",
 		}
 		cmd := fmt.Sprintf("curl -s -o /dev/null  --connect-timeout 5 --max-time 10 -w \"%%{http_code}\" %s", endpoint.Address)
-		output, err := session.CombinedOutput(cmd)
+		output, err := runner.Run(ctx, cmd)
 		if err != nil {
 			currentStatus.Error = err.Error()
 			statuses = append(statuses, currentStatus)
@@ -406,21 +367,21 @@ func (m *MonitoringConfig) getConnectivityHTTP(client *ssh.Client, endpoints []H
 	return statuses, nil
 }
 
-func (m *MonitoringConfig) getConnectivity(client *ssh.Client, tcpEndpoints []TCPEndpoint, icmpEndpoints []ICMPEndpoint, httpEndpoints []HTTPEndpoint) (map[string]ConnectivityStatus, error) {
+func (m *MonitoringConfig) getConnectivity(ctx context.Context, runner Runner, tcpEndpoints []TCPEndpoint, icmpEndpoints []ICMPEndpoint, httpEndpoints []HTTPEndpoint) (map[string]ConnectivityStatus, error) {
 	log.Printf("[%s] Getting TCP connectivity", m.NodeName)
-	tcpStatuses, err := m.getConnectivityTCP(client, tcpEndpoints)
+	tcpStatuses, err := m.getConnectivityTCP(ctx, runner, tcpEndpoints)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get TCP connectivity: %v", err)
+		return nil, fmt.Errorf("failed to get TCP connectivity: %w", err)
 	}
 	log.Printf("[%s] Getting ICMP connectivity", m.NodeName)
-	icmpStatuses, err := m.getConnectivityICMP(client, icmpEndpoints)
+	icmpStatuses, err := m.getConnectivityICMP(ctx, runner, icmpEndpoints)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get ICMP connectivity: %v", err)
+		return nil, fmt.Errorf("failed to get ICMP connectivity: %w", err)
 	}
 	log.Printf("[%s] Getting HTTP connectivity", m.NodeName)
-	httpStatuses, err := m.getConnectivityHTTP(client, httpEndpoints)
+	httpStatuses, err := m.getConnectivityHTTP(ctx, runner, httpEndpoints)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get HTTP connectivity: %v", err)
+		return nil, fmt.Errorf("failed to get HTTP connectivity: %w", err)
 	}
 
 	connectivity := make(map[string]ConnectivityStatus)
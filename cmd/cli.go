@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const (
+	exitOK     = 0
+	exitFailed = 1
+	exitUsage  = 2
+)
+
+const usage = `Usage: lookout-connect [--config path] <command> [arguments]
+
+Commands:
+  run           run checks on schedule (default)
+  once          run all checks once, exit with 1 if any check failed
+  check <node>  run checks for a single node and print the result
+  validate      load and lint the config without connecting anywhere
+
+The config path defaults to $LOOKOUT_CONFIG, then to %s.
+`
+
+func newFlagSet(name string, configPath *string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(configPath, "config", *configPath, "path to config.yaml")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), usage, DefaultConfigPath)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// RunCLI parses arguments, runs the selected command and returns the exit code.
+func RunCLI(args []string) int {
+	configPath := ""
+	global := newFlagSet("lookout-connect", &configPath)
+	if err := global.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	command := "run"
+	args = global.Args()
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	// Flags are also accepted after the command, e.g. "check --config x alpha".
+	sub := newFlagSet(command, &configPath)
+	if err := sub.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	args = sub.Args()
+	path := ConfigPath(configPath)
+
+	// SIGINT and SIGTERM cancel every check; see RunSchedule for the grace period.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch command {
+	case "run":
+		return cmdRun(ctx, path)
+	case "once":
+		return cmdOnce(ctx, path)
+	case "check":
+		if len(args) != 1 {
+			fmt.Fprintln(os.Stderr, "check requires exactly one node name")
+			return exitUsage
+		}
+		return cmdCheck(ctx, path, args[0])
+	case "validate":
+		return cmdValidate(path)
+	case "help":
+		global.SetOutput(os.Stdout)
+		global.Usage()
+		return exitOK
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", command)
+		global.Usage()
+		return exitUsage
+	}
+}
+
+func cmdRun(ctx context.Context, path string) int {
+	config, err := LoadConfig(path)
+	if err != nil {
+		log.Printf("Failed to load config: %v", err)
+		return exitUsage
+	}
+	RunSchedule(ctx, config)
+	return exitOK
+}
+
+func cmdOnce(ctx context.Context, path string) int {
+	config, err := LoadConfig(path)
+	if err != nil {
+		log.Printf("Failed to load config: %v", err)
+		return exitUsage
+	}
+	if err := InitializeMQTTConnections(ctx, config.Export.MQTT); err != nil {
+		log.Printf("Warning: Failed to initialize MQTT connections: %v", err)
+	}
+	results := InitChecks(ctx, config)
+	CleanupMQTTConnections(config.Export.MQTT)
+
+	code := exitOK
+	for _, result := range results {
+		if result.Failed() {
+			log.Printf("[%s] Checks failed", result.NodeCfgName)
+			code = exitFailed
+		}
+	}
+	return code
+}
+
+func cmdCheck(ctx context.Context, path string, nodeName string) int {
+	config, err := LoadConfig(path)
+	if err != nil {
+		log.Printf("Failed to load config: %v", err)
+		return exitUsage
+	}
+	for _, node := range config.Nodes {
+		if node.NodeName != nodeName {
+			continue
+		}
+		pool := NewSSHPool()
+		defer pool.Close()
+		result := node.PerformChecks(ctx, config.Connectivity, pool)
+		fmt.Print(result.String())
+		if result.Failed() {
+			return exitFailed
+		}
+		return exitOK
+	}
+	fmt.Fprintf(os.Stderr, "node %q not found in %s\n", nodeName, path)
+	return exitUsage
+}
+
+func cmdValidate(path string) int {
+	config, err := LoadConfig(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailed
+	}
+	fmt.Printf("%s: OK (%d nodes, %d MQTT exporters)\n", path, len(config.Nodes), len(config.Export.MQTT))
+	return exitOK
+}
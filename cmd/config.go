@@ -1,10 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"reflect"
 	"slices"
 	"strconv"
 	"strings"
@@ -30,11 +34,19 @@ type HTTPEndpoint struct {
 }
 
 type MonitoringConfig struct {
-	NodeName string `yaml:"name"`
-	UserName string `yaml:"user"`
-	IP       string `yaml:"ip"`
-	Port     int    `yaml:"port"`
-	IDFile   string `yaml:"id_file"`
+	NodeName string               `yaml:"name"`
+	UserName string               `yaml:"user"`
+	IP       string               `yaml:"ip"`
+	Port     int                  `yaml:"port" default:"22"`
+	IDFile   string               `yaml:"id_file"`
+	Auth     AuthConfig           `yaml:"auth"`
+	HostKey  HostKeyConfig        `yaml:"host_key"`
+	Jump     []JumpHost           `yaml:"jump"`
+	Tags     []string             `yaml:"tags"`
+	Timeouts TimeoutConfig        `yaml:"timeouts"`
+	Groups   []string             `yaml:"groups"`
+	Checks   map[string]CheckSpec `yaml:"checks"`
+	State    *StateStore          `yaml:"-"`
 }
 
 type ConnectivityConfig struct {
@@ -46,8 +58,12 @@ type ConnectivityConfig struct {
 type ScheduleConfig struct {
 	IntervalRaw string        `yaml:"interval" default:"4h"`
 	SplitterRaw string        `yaml:"splitter" default:"0m"`
+	WatchRaw    string        `yaml:"watch"`
+	GraceRaw    string        `yaml:"grace_period" default:"10s"`
 	Interval    time.Duration `yaml:"-"`
 	Splitter    time.Duration `yaml:"-"`
+	Watch       time.Duration `yaml:"-"`
+	GracePeriod time.Duration `yaml:"-"`
 }
 
 type ExportConfig struct {
@@ -55,10 +71,16 @@ type ExportConfig struct {
 }
 
 type Config struct {
-	Nodes        []MonitoringConfig `yaml:"nodes"`
-	Connectivity ConnectivityConfig `yaml:"connectivity"`
-	Export       ExportConfig       `yaml:"export"`
-	Schedule     ScheduleConfig     `yaml:"schedule"`
+	Nodes        []MonitoringConfig   `yaml:"nodes"`
+	Inventory    InventoryConfig      `yaml:"inventory"`
+	Connectivity ConnectivityConfig   `yaml:"connectivity"`
+	Export       ExportConfig         `yaml:"export"`
+	Schedule     ScheduleConfig       `yaml:"schedule"`
+	Timeouts     TimeoutConfig        `yaml:"timeouts"`
+	Groups       []GroupConfig        `yaml:"groups"`
+	Checks       map[string]CheckSpec `yaml:"checks"`
+	StateFile    string               `yaml:"state_file"`
+	Path         string               `yaml:"-"`
 }
 
 func (m *MonitoringConfig) String() string {
@@ -70,6 +92,23 @@ func (m *MonitoringConfig) String() string {
 	sb.WriteString(m.UserName)
 	sb.WriteString(", IP: ")
 	sb.WriteString(m.IP)
+	if len(m.Jump) > 0 {
+		sb.WriteString(", Jump: ")
+		for i, jump := range m.Jump {
+			if i > 0 {
+				sb.WriteString(" -> ")
+			}
+			sb.WriteString(jump.IP)
+		}
+	}
+	if len(m.Tags) > 0 {
+		sb.WriteString(", Tags: ")
+		sb.WriteString(strings.Join(m.Tags, ","))
+	}
+	if len(m.Groups) > 0 {
+		sb.WriteString(", Groups: ")
+		sb.WriteString(strings.Join(m.Groups, ","))
+	}
 	sb.WriteString("\n")
 	return sb.String()
 }
@@ -156,6 +195,14 @@ func (s *ScheduleConfig) String() string {
 	sb.WriteString("Splitter: ")
 	sb.WriteString(s.Splitter.String())
 	sb.WriteString("\n")
+	sb.WriteString("Grace Period: ")
+	sb.WriteString(s.GracePeriod.String())
+	sb.WriteString("\n")
+	if s.Watch > 0 {
+		sb.WriteString("Watch: ")
+		sb.WriteString(s.Watch.String())
+		sb.WriteString("\n")
+	}
 	return sb.String()
 }
 
@@ -175,8 +222,29 @@ func (c *Config) String() string {
 	return sb.String()
 }
 
-func LoadConfig() (Config, error) {
-	configPath := filepath.Join("etc", "lookout-connect", "config.yaml")
+// DefaultConfigPath is used when neither --config nor LOOKOUT_CONFIG is set.
+var DefaultConfigPath = filepath.Join("etc", "lookout-connect", "config.yaml")
+
+func ConfigPath(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if env := os.Getenv("LOOKOUT_CONFIG"); env != "" {
+		return env
+	}
+	return DefaultConfigPath
+}
+
+// StateDir is where state_file and the tofu known hosts are kept unless set:
+// LOOKOUT_STATE_DIR, e.g. a volume in a container, or next to the config.
+func StateDir(configPath string) string {
+	if env := os.Getenv("LOOKOUT_STATE_DIR"); env != "" {
+		return env
+	}
+	return filepath.Dir(configPath)
+}
+
+func LoadConfig(configPath string) (Config, error) {
 	if _, err := os.Stat(configPath); err != nil {
 		return Config{}, fmt.Errorf("config file not found: %v", err)
 	}
@@ -186,19 +254,62 @@ func LoadConfig() (Config, error) {
 		return Config{}, fmt.Errorf("failed to read config file: %v", err)
 	}
 
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
 		return Config{}, fmt.Errorf("failed to parse config file: %v", err)
 	}
+	validator := &configValidator{root: &root}
 
-	config.Schedule.Interval, err = time.ParseDuration(config.Schedule.IntervalRaw)
-	if err != nil {
-		return Config{}, fmt.Errorf("failed to parse interval: %v", err)
+	var config Config
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return Config{}, fmt.Errorf("failed to parse config file: %v", err)
+		}
+		validator.addYAMLErrors(typeErr)
 	}
+	config.Path = configPath
 
-	config.Schedule.Splitter, err = time.ParseDuration(config.Schedule.SplitterRaw)
+	validator.validateExplicit(&config)
+	applyDefaults(reflect.ValueOf(&config))
+
+	explicitNodes := len(config.Nodes)
+	config.Nodes, err = config.Inventory.Merge(config.Nodes)
 	if err != nil {
-		return Config{}, fmt.Errorf("failed to parse splitter: %v", err)
+		validator.addf(at("inventory"), "failed to load inventory: %v", err)
+	}
+
+	tofuStateFile := filepath.Join(StateDir(configPath), "known_hosts.tofu")
+	for i := range config.Nodes {
+		config.Nodes[i].HostKey.defaultStateFile(tofuStateFile)
+		for j := range config.Nodes[i].Jump {
+			config.Nodes[i].Jump[j].HostKey.defaultStateFile(tofuStateFile)
+		}
+	}
+
+	validator.validateMerged(&config, explicitNodes)
+	if len(validator.problems) > 0 {
+		validator.sortProblems()
+		return Config{}, &ConfigError{File: configPath, Problems: validator.problems}
+	}
+	for i := range config.Nodes {
+		config.Nodes[i].Timeouts = config.Nodes[i].Timeouts.Merge(config.Timeouts)
+	}
+	config.resolveChecks()
+	validator.validateCPUIntervals(&config)
+	if len(validator.problems) > 0 {
+		validator.sortProblems()
+		return Config{}, &ConfigError{File: configPath, Problems: validator.problems}
+	}
+
+	if config.StateFile == "" {
+		config.StateFile = filepath.Join(StateDir(configPath), "state.json")
+	}
+	state := NewStateStore(config.StateFile)
+	for i := range config.Nodes {
+		config.Nodes[i].State = state
 	}
 
 	for _, node := range config.Nodes {
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const CheckCPU = "cpu"
+
+func init() {
+	RegisterChecker(cpuCheck{}, true)
+}
+
+type cpuOptions struct {
+	// Interval between the two /proc/stat samples, 0 reports load only.
+	Interval time.Duration `yaml:"interval" default:"1s"`
+}
+
+func (o *cpuOptions) Validate() error {
+	if o.Interval < 0 || o.Interval > time.Minute {
+		return fmt.Errorf("interval must be between 0s and 1m, got %s", o.Interval)
+	}
+	return nil
+}
+
+// validateCPUIntervals checks the interval of every node against the timeout
+// of its cpu check, which needs time for more than the sampling.
+func (v *configValidator) validateCPUIntervals(config *Config) {
+	for i, node := range config.Nodes {
+		enabled := node.enabledChecks()
+		j := slices.IndexFunc(enabled, func(c enabledCheck) bool { return c.checker.Name() == CheckCPU })
+		if j < 0 {
+			continue
+		}
+		o := enabled[j].options.(*cpuOptions)
+		spec := node.Checks[CheckCPU]
+		timeout := node.Timeouts.Check(CheckCPU)
+		if o.Interval < timeout/2 {
+			continue
+		}
+		path := at("nodes", i, "checks", CheckCPU, "interval")
+		format := "node %s: interval %s must be below half the cpu check timeout of %s"
+		if spec.Raw != nil {
+			v.addLine(spec.Raw.Line, path, format, node.NodeName, o.Interval, timeout)
+		} else {
+			v.addf(path, format, node.NodeName, o.Interval, timeout)
+		}
+	}
+}
+
+// CPUInfo holds load averages, also divided by the number of cores, and
+// the share of CPU time spent in each state between the two samples.
+type CPUInfo struct {
+	Cores         int     `json:"cores"`
+	Load1         float64 `json:"load1"`
+	Load5         float64 `json:"load5"`
+	Load15        float64 `json:"load15"`
+	Load1PerCore  float64 `json:"load1_per_core"`
+	Load5PerCore  float64 `json:"load5_per_core"`
+	Load15PerCore float64 `json:"load15_per_core"`
+	User          float64 `json:"user"`
+	System        float64 `json:"system"`
+	IOWait        float64 `json:"iowait"`
+	Steal         float64 `json:"steal"`
+	Idle          float64 `json:"idle"`
+	Usage         float64 `json:"usage"`
+	Interval      float64 `json:"interval"`
+}
+
+func (c *CPUInfo) String() string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("\tCores: %d, Load: %.2f %.2f %.2f, Per Core: %.2f %.2f %.2f\n",
+		c.Cores, c.Load1, c.Load5, c.Load15, c.Load1PerCore, c.Load5PerCore, c.Load15PerCore))
+	if c.Interval > 0 {
+		sb.WriteString(fmt.Sprintf("\tUser: %.1f%%, System: %.1f%%, IOWait: %.1f%%, Steal: %.1f%%, Idle: %.1f%% (over %gs)\n",
+			c.User, c.System, c.IOWait, c.Steal, c.Idle, c.Interval))
+	}
+	return sb.String()
+}
+
+type cpuCheck struct{}
+
+func (cpuCheck) Name() string { return CheckCPU }
+func (cpuCheck) Options() any { return &cpuOptions{} }
+
+func (cpuCheck) Run(ctx context.Context, env *CheckEnv, opts any) (any, error) {
+	o := opts.(*cpuOptions)
+	// Everything in one session, so the samples are taken on the host and
+	// the interval does not include SSH round trips.
+	cmd := "cat /proc/loadavg; nproc 2>/dev/null || grep -c ^processor /proc/cpuinfo"
+	if o.Interval > 0 {
+		cmd += fmt.Sprintf("; grep '^cpu ' /proc/stat; sleep %g; grep '^cpu ' /proc/stat", o.Interval.Seconds())
+	}
+	output, err := env.Runner.Run(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute command: %w", err)
+	}
+	return parseCPU(cmd, output, o.Interval)
+}
+
+func parseCPU(cmd string, output []byte, interval time.Duration) (*CPUInfo, error) {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	expected := 2
+	if interval > 0 {
+		expected = 4
+	}
+	if len(lines) != expected {
+		return nil, parseErrorf(cmd, "expected %d lines, got %d: %s", expected, len(lines), string(output))
+	}
+
+	info := &CPUInfo{}
+	load := strings.Fields(lines[0])
+	if len(load) < 3 {
+		return nil, parseErrorf(cmd, "unexpected /proc/loadavg: %s", lines[0])
+	}
+	for i, value := range []*float64{&info.Load1, &info.Load5, &info.Load15} {
+		n, err := strconv.ParseFloat(load[i], 64)
+		if err != nil {
+			return nil, parseErrorf(cmd, "unexpected /proc/loadavg: %s", lines[0])
+		}
+		*value = n
+	}
+	cores, err := strconv.Atoi(strings.TrimSpace(lines[1]))
+	if err != nil || cores < 1 {
+		return nil, parseErrorf(cmd, "unexpected core count: %s", lines[1])
+	}
+	info.Cores = cores
+	info.Load1PerCore = info.Load1 / float64(cores)
+	info.Load5PerCore = info.Load5 / float64(cores)
+	info.Load15PerCore = info.Load15 / float64(cores)
+	if interval == 0 {
+		return info, nil
+	}
+
+	before, err := parseCPUStat(lines[2])
+	if err != nil {
+		return nil, parseErrorf(cmd, "%v", err)
+	}
+	after, err := parseCPUStat(lines[3])
+	if err != nil {
+		return nil, parseErrorf(cmd, "%v", err)
+	}
+	var delta cpuTimes
+	total := 0.0
+	for i := range delta {
+		delta[i] = max(after[i]-before[i], 0)
+		total += float64(delta[i])
+	}
+	info.Interval = interval.Seconds()
+	if total == 0 {
+		return info, nil
+	}
+	percent := func(values ...int64) float64 {
+		sum := int64(0)
+		for _, v := range values {
+			sum += v
+		}
+		return float64(sum) * 100 / total
+	}
+	info.User = percent(delta[cpuUser], delta[cpuNice])
+	info.System = percent(delta[cpuSystem], delta[cpuIRQ], delta[cpuSoftIRQ])
+	info.IOWait = percent(delta[cpuIOWait])
+	info.Steal = percent(delta[cpuSteal])
+	info.Idle = percent(delta[cpuIdle])
+	info.Usage = 100 - info.Idle - info.IOWait
+	return info, nil
+}
+
+// Columns of the cpu line in /proc/stat. Guest time is already part of
+// user time, so it is not counted again.
+const (
+	cpuUser = iota
+	cpuNice
+	cpuSystem
+	cpuIdle
+	cpuIOWait
+	cpuIRQ
+	cpuSoftIRQ
+	cpuSteal
+	cpuColumns
+)
+
+type cpuTimes [cpuColumns]int64
+
+func parseCPUStat(line string) (cpuTimes, error) {
+	var times cpuTimes
+	fields := strings.Fields(line)
+	// Old kernels have fewer columns, missing ones stay zero.
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return times, fmt.Errorf("unexpected /proc/stat line: %s", line)
+	}
+	for i := 0; i < cpuColumns && i+1 < len(fields); i++ {
+		n, err := strconv.ParseInt(fields[i+1], 10, 64)
+		if err != nil {
+			return times, fmt.Errorf("unexpected /proc/stat line: %s", line)
+		}
+		times[i] = n
+	}
+	return times, nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const CheckDocker = "docker"
+
+func init() {
+	RegisterChecker(dockerCheck{}, false)
+}
+
+type dockerOptions struct {
+	// Include and Exclude are container name patterns, e.g. "app-*".
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+	// Labels are "key" or "key=value", a container must carry all of them.
+	Labels []string `yaml:"labels"`
+	// Inspect adds restart counts, start times and health from `docker inspect`.
+	Inspect bool `yaml:"inspect" default:"true"`
+}
+
+func (o *dockerOptions) keep(c dockerPSLine) bool {
+	name := c.name()
+	if len(o.Include) > 0 && !matchAny(o.Include, name) {
+		return false
+	}
+	if matchAny(o.Exclude, name) {
+		return false
+	}
+	labels := c.labels()
+	for _, label := range o.Labels {
+		key, value, hasValue := strings.Cut(label, "=")
+		actual, ok := labels[key]
+		if !ok || (hasValue && actual != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// DockerInfo is keyed by container name. Status is critical when a
+// container is restarting, dead, unhealthy or exited with a non-zero code.
+type DockerInfo struct {
+	Containers map[string]Container `json:"containers"`
+	Status     string               `json:"status"`
+}
+
+type Container struct {
+	Name   string `json:"name"`
+	ID     string `json:"id"`
+	Image  string `json:"image"`
+	State  string `json:"state"`
+	Health string `json:"health,omitempty"`
+	// Status is docker's own summary, e.g. "Up 2 hours (healthy)".
+	Status       string     `json:"status"`
+	ExitCode     *int       `json:"exit_code,omitempty"`
+	RestartCount int        `json:"restart_count"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	Uptime       float64    `json:"uptime"`
+}
+
+func (c Container) failed() bool {
+	return c.State == "restarting" || c.State == "dead" || c.Health == "unhealthy" ||
+		(c.ExitCode != nil && *c.ExitCode != 0)
+}
+
+func (d *DockerInfo) String() string {
+	names := make([]string, 0, len(d.Containers))
+	for name := range d.Containers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("\tStatus: %s, Containers: %d\n", d.Status, len(d.Containers)))
+	for _, name := range names {
+		c := d.Containers[name]
+		sb.WriteString(fmt.Sprintf("\t%s (%s): %s, Restarts: %d", c.Name, c.Image, c.Status, c.RestartCount))
+		if c.State == "running" && c.StartedAt != nil {
+			sb.WriteString(fmt.Sprintf(", Uptime: %s", time.Duration(c.Uptime)*time.Second))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+type dockerCheck struct{}
+
+func (dockerCheck) Name() string { return CheckDocker }
+func (dockerCheck) Options() any { return &dockerOptions{} }
+
+func (dockerCheck) Run(ctx context.Context, env *CheckEnv, opts any) (any, error) {
+	o := opts.(*dockerOptions)
+	cmd := "docker ps -a --no-trunc --format '{{json .}}'"
+	output, err := env.Runner.Run(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute command: %w", err)
+	}
+	lines, err := parseDockerPS(cmd, output)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &DockerInfo{Containers: make(map[string]Container), Status: StatusOK}
+	var ids []string
+	for _, line := range lines {
+		if !o.keep(line) {
+			continue
+		}
+		c := Container{
+			Name:   line.name(),
+			ID:     line.ID,
+			Image:  line.Image,
+			State:  line.state(),
+			Status: line.Status,
+			Health: dockerHealth(line.Status),
+		}
+		if match := exitedPattern.FindStringSubmatch(line.Status); match != nil {
+			code, _ := strconv.Atoi(match[1])
+			c.ExitCode = &code
+		}
+		info.Containers[c.Name] = c
+		ids = append(ids, c.ID)
+	}
+
+	if o.Inspect && len(ids) > 0 {
+		// A container removed since `docker ps` fails inspect, the rest is still printed.
+		inspectCmd := "docker inspect --format '{{.Id}} {{.RestartCount}} {{.State.StartedAt}} " +
+			"{{if .State.Health}}{{.State.Health.Status}}{{end}}' " + strings.Join(ids, " ")
+		output, err := env.Runner.Run(ctx, inspectCmd)
+		if err != nil && len(output) == 0 {
+			log.Printf("[%s] Skipping docker inspect: %v", env.Node.NodeName, err)
+		} else {
+			applyDockerInspect(info, output, time.Now())
+		}
+	}
+
+	for _, c := range info.Containers {
+		if c.failed() {
+			info.Status = StatusCritical
+		}
+	}
+	return info, nil
+}
+
+// dockerPSLine is one line of `docker ps --format '{{json .}}'`, which has
+// every field as a string.
+type dockerPSLine struct {
+	ID     string `json:"ID"`
+	Names  string `json:"Names"`
+	Image  string `json:"Image"`
+	State  string `json:"State"`
+	Status string `json:"Status"`
+	Labels string `json:"Labels"`
+}
+
+// name is the container's own name, linked containers list more names.
+func (l dockerPSLine) name() string {
+	name, _, _ := strings.Cut(l.Names, ",")
+	return name
+}
+
+// state falls back to the status text for docker before 20.10, which has no State.
+func (l dockerPSLine) state() string {
+	if l.State != "" {
+		return l.State
+	}
+	word, _, _ := strings.Cut(l.Status, " ")
+	switch word {
+	case "Up":
+		if strings.Contains(l.Status, "(Paused)") {
+			return "paused"
+		}
+		return "running"
+	case "Exited":
+		return "exited"
+	}
+	return strings.ToLower(word)
+}
+
+func (l dockerPSLine) labels() map[string]string {
+	labels := make(map[string]string)
+	if l.Labels == "" {
+		return labels
+	}
+	for _, label := range strings.Split(l.Labels, ",") {
+		key, value, _ := strings.Cut(label, "=")
+		labels[key] = value
+	}
+	return labels
+}
+
+func parseDockerPS(cmd string, output []byte) ([]dockerPSLine, error) {
+	var lines []dockerPSLine
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var parsed dockerPSLine
+		if err := json.Unmarshal([]byte(line), &parsed); err != nil {
+			return nil, parseErrorf(cmd, "unexpected docker ps line: %s", line)
+		}
+		lines = append(lines, parsed)
+	}
+	return lines, nil
+}
+
+var (
+	exitedPattern = regexp.MustCompile(`^Exited \((\d+)\)`)
+	healthPattern = regexp.MustCompile(`\((healthy|unhealthy|health: starting)\)`)
+)
+
+// dockerHealth reads the health from the status text, for when inspect is off.
+func dockerHealth(status string) string {
+	match := healthPattern.FindStringSubmatch(status)
+	if match == nil {
+		return ""
+	}
+	return strings.TrimPrefix(match[1], "health: ")
+}
+
+// applyDockerInspect reads lines of id, restart count, start time and health.
+func applyDockerInspect(info *DockerInfo, output []byte, now time.Time) {
+	byID := make(map[string]string, len(info.Containers))
+	for name, c := range info.Containers {
+		byID[c.ID] = name
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		name, ok := byID[fields[0]]
+		if !ok {
+			continue
+		}
+		c := info.Containers[name]
+		if n, err := strconv.Atoi(fields[1]); err == nil {
+			c.RestartCount = n
+		}
+		// Never started containers have the zero time.
+		if started, err := time.Parse(time.RFC3339Nano, fields[2]); err == nil && started.Year() > 1 {
+			c.StartedAt = &started
+			if c.State == "running" {
+				c.Uptime = now.Sub(started).Seconds()
+			}
+		}
+		if len(fields) > 3 {
+			c.Health = fields[3]
+		}
+		info.Containers[name] = c
+	}
+}
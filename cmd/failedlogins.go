@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const CheckFailedLogins = "failed_logins"
+
+func init() {
+	RegisterChecker(failedLoginsCheck{}, false)
+}
+
+const (
+	FailedLoginSourceAuto    = "auto"
+	FailedLoginSourceLastb   = "lastb"
+	FailedLoginSourceJournal = "journal"
+	FailedLoginSourceFile    = "file"
+)
+
+type failedLoginsOptions struct {
+	// Source is auto, which tries lastb, journal and file in this order, or one of them.
+	Source string `yaml:"source" default:"auto"`
+	// Window is how far back the first run looks, later runs start where
+	// the previous one ended, but never further back than Window.
+	Window time.Duration `yaml:"window" default:"24h"`
+	// Top is how many source IPs and users to list as top offenders.
+	Top int `yaml:"top" default:"5"`
+}
+
+func (o *failedLoginsOptions) Validate() error {
+	sources := []string{FailedLoginSourceAuto, FailedLoginSourceLastb, FailedLoginSourceJournal, FailedLoginSourceFile}
+	if !slices.Contains(sources, o.Source) {
+		return fmt.Errorf("source must be one of %s, got %q", strings.Join(sources, ", "), o.Source)
+	}
+	if o.Window <= 0 {
+		return fmt.Errorf("window must be positive, got %s", o.Window)
+	}
+	if o.Top < 0 {
+		return fmt.Errorf("top must not be negative, got %d", o.Top)
+	}
+	return nil
+}
+
+// FailedLogins summarizes failed SSH logins from Since, which is the end
+// of the previous published run, until Until.
+type FailedLogins struct {
+	Source   string         `json:"source"`
+	Since    time.Time      `json:"since"`
+	Until    time.Time      `json:"until"`
+	Total    int            `json:"total"`
+	ByIP     map[string]int `json:"by_ip"`
+	ByUser   map[string]int `json:"by_user"`
+	TopIPs   []LoginCount   `json:"top_ips"`
+	TopUsers []LoginCount   `json:"top_users"`
+}
+
+type LoginCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func (f *FailedLogins) String() string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("\tSource: %s, Since: %s, Until: %s, Total: %d\n",
+		f.Source, f.Since.Format(time.RFC3339), f.Until.Format(time.RFC3339), f.Total))
+	for _, c := range f.TopIPs {
+		sb.WriteString(fmt.Sprintf("\tIP: %s, Failures: %d\n", c.Name, c.Count))
+	}
+	for _, c := range f.TopUsers {
+		sb.WriteString(fmt.Sprintf("\tUser: %s, Failures: %d\n", c.Name, c.Count))
+	}
+	return sb.String()
+}
+
+type failedLogin struct {
+	time time.Time
+	user string
+	ip   string
+}
+
+type failedLoginsState struct {
+	Until time.Time `json:"until"`
+}
+
+type failedLoginsCheck struct{}
+
+func (failedLoginsCheck) Name() string { return CheckFailedLogins }
+func (failedLoginsCheck) Options() any { return &failedLoginsOptions{} }
+
+func (failedLoginsCheck) Run(ctx context.Context, env *CheckEnv, opts any) (any, error) {
+	o := opts.(*failedLoginsOptions)
+	nodeName := env.Node.NodeName
+	now := time.Now().UTC().Truncate(time.Second)
+	since := now.Add(-o.Window)
+	var previous failedLoginsState
+	if found, err := env.State.Load(nodeName, CheckFailedLogins, &previous); err != nil {
+		log.Printf("[%s] Unable to load end of previous failed logins window: %v", nodeName, err)
+	} else if found && previous.Until.After(since) {
+		since = previous.Until
+	}
+
+	sources := []string{o.Source}
+	if o.Source == FailedLoginSourceAuto {
+		sources = []string{FailedLoginSourceLastb, FailedLoginSourceJournal, FailedLoginSourceFile}
+	}
+	var events []failedLogin
+	var source string
+	var errs []error
+	for _, source = range sources {
+		var err error
+		events, err = readFailedLogins(ctx, env.Runner, source, since, now)
+		if err == nil {
+			break
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", source, err))
+		if ctx.Err() != nil || source == sources[len(sources)-1] {
+			return nil, errors.Join(errs...)
+		}
+		log.Printf("[%s] Unable to read failed logins from %s, trying the next source: %v", nodeName, source, err)
+	}
+
+	result := &FailedLogins{
+		Source: source,
+		Since:  since,
+		Until:  now,
+		ByIP:   make(map[string]int),
+		ByUser: make(map[string]int),
+	}
+	for _, event := range events {
+		if event.time.Before(since) || !event.time.Before(now) {
+			continue
+		}
+		result.Total++
+		result.ByIP[event.ip]++
+		result.ByUser[event.user]++
+	}
+	result.TopIPs = topCounts(result.ByIP, o.Top)
+	result.TopUsers = topCounts(result.ByUser, o.Top)
+	return result, nil
+}
+
+// saveFailedLoginsUntil records the end of the window of result, where the
+// next run starts. It is called only once result was published, otherwise
+// the failures in the window would never be reported.
+func (c *MonitoringConfig) saveFailedLoginsUntil(result *MonitoringResult) {
+	info, ok := result.Checks[CheckFailedLogins].Result.(*FailedLogins)
+	if !ok {
+		return
+	}
+	if err := c.State.Save(c.NodeName, CheckFailedLogins, failedLoginsState{Until: info.Until}); err != nil {
+		log.Printf("[%s] Unable to save end of failed logins window: %v", c.NodeName, err)
+	}
+}
+
+// topCounts returns the n largest counts, ties ordered by name.
+func topCounts(counts map[string]int, n int) []LoginCount {
+	top := make([]LoginCount, 0, len(counts))
+	for name, count := range counts {
+		top = append(top, LoginCount{Name: name, Count: count})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Name < top[j].Name
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// readFailedLogins reads failed SSH logins between since and until. lastb
+// runs with TZ=UTC0 and journalctl with --utc, so their times are UTC.
+func readFailedLogins(ctx context.Context, runner Runner, source string, since time.Time, until time.Time) ([]failedLogin, error) {
+	var cmd string
+	switch source {
+	case FailedLoginSourceLastb:
+		// btmp is root only, lastb fails for anyone else.
+		cmd = fmt.Sprintf("LC_ALL=C TZ=UTC0 lastb -F -w -i --since '%s'", since.Format(time.DateTime))
+	case FailedLoginSourceJournal:
+		// The unit is ssh on Debian and sshd elsewhere.
+		cmd = fmt.Sprintf("journalctl -u ssh -u sshd --utc -o short-iso --no-pager --quiet --since @%d --until @%d",
+			since.Unix(), until.Unix())
+	case FailedLoginSourceFile:
+		// date tells the zone of the classic syslog timestamps, which have none.
+		// auth.log is Debian's, secure the one of RHEL and its relatives.
+		cmd = "date +%z; for f in /var/log/auth.log /var/log/secure; do " +
+			"if [ -r $f ]; then grep 'sshd.*Failed' $f; exit $?; fi; done; " +
+			"echo 'neither /var/log/auth.log nor /var/log/secure is readable' >&2; exit 2"
+	}
+	output, err := runner.Run(ctx, cmd)
+	// grep exits with 1 when nothing matched, that is not an error.
+	var cmdErr *CommandError
+	if err != nil && !(source == FailedLoginSourceFile && errors.As(err, &cmdErr) && cmdErr.ExitCode == 1 && len(output) > 0) {
+		return nil, fmt.Errorf("failed to execute command: %w", err)
+	}
+	switch source {
+	case FailedLoginSourceLastb:
+		return parseLastb(cmd, output)
+	case FailedLoginSourceJournal:
+		return parseSSHDLog(cmd, output, time.UTC, until)
+	default:
+		zone, rest, _ := strings.Cut(string(output), "\n")
+		offset, err := time.Parse("-0700", strings.TrimSpace(zone))
+		if err != nil {
+			return nil, parseErrorf(cmd, "unexpected time zone: %s", zone)
+		}
+		return parseSSHDLog(cmd, []byte(rest), offset.Location(), until)
+	}
+}
+
+// parseLastb parses `lastb -F -w -i` lines such as
+//
+//	admin    ssh:notty    203.0.113.7      Mon Oct 14 10:00:01 2024 - Mon Oct 14 10:00:01 2024  (00:00)
+//
+// and skips failures on local terminals. SSH lines that do not parse are
+// skipped too, unless none parses at all.
+func parseLastb(cmd string, output []byte) ([]failedLogin, error) {
+	var events []failedLogin
+	skipped := 0
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.HasPrefix(fields[1], "ssh") {
+			continue
+		}
+		if len(fields) < 8 {
+			skipped++
+			continue
+		}
+		t, err := time.Parse(lastTimeLayout, strings.Join(fields[3:8], " "))
+		if err != nil {
+			skipped++
+			continue
+		}
+		events = append(events, failedLogin{time: t, user: fields[0], ip: fields[2]})
+	}
+	if len(events) == 0 && skipped > 0 {
+		return nil, parseErrorf(cmd, "none of %d ssh lines of lastb output could be parsed", skipped)
+	}
+	return events, nil
+}
+
+// Since OpenSSH 9.8, sessions and their failures are logged by sshd-session.
+var sshdFailurePattern = regexp.MustCompile(`sshd(?:-session)?\[\d+\]: Failed \S+ for (?:invalid user )?(.*) from (\S+) port \d+`)
+
+// parseSSHDLog parses sshd lines from the journal or syslog, e.g.
+//
+//	2024-10-14T10:00:01+0000 vps sshd[812]: Failed password for invalid user admin from 203.0.113.7 port 50122 ssh2
+//	Oct 14 10:00:01 vps sshd[812]: Failed password for root from 203.0.113.7 port 50122 ssh2
+//	2024-10-14T10:00:01+0000 vps sshd-session[812]: Failed publickey for root from 2001:db8::7 port 50122 ssh2
+//
+// Classic syslog timestamps have no year and are in the zone loc; the year
+// is the one that puts them closest before until.
+func parseSSHDLog(cmd string, output []byte, loc *time.Location, until time.Time) ([]failedLogin, error) {
+	var events []failedLogin
+	for _, line := range strings.Split(string(output), "\n") {
+		match := sshdFailurePattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		fields := strings.Fields(line)
+		var t time.Time
+		var err error
+		if len(fields) > 0 && len(fields[0]) >= len("2006-01-02T15:04:05") && fields[0][4] == '-' {
+			t, err = parseLogTime(fields[0])
+		} else if len(fields) >= 3 {
+			t, err = time.ParseInLocation(time.Stamp, strings.Join(fields[:3], " "), loc)
+			t = t.AddDate(until.In(loc).Year(), 0, 0)
+			if t.After(until.Add(24 * time.Hour)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+		}
+		if err != nil || t.IsZero() {
+			return nil, parseErrorf(cmd, "unexpected log line: %s", line)
+		}
+		events = append(events, failedLogin{time: t.UTC(), user: match[1], ip: match[2]})
+	}
+	return events, nil
+}
+
+// parseLogTime parses ISO timestamps of journalctl -o short-iso and rsyslog.
+func parseLogTime(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02T15:04:05-0700", time.RFC3339Nano} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unexpected timestamp %s", value)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readFailedLoginsFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "failedlogins", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseSSHDLog(t *testing.T) {
+	until := time.Date(2024, 10, 15, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		file string
+		loc  *time.Location
+		want []failedLogin
+	}{
+		{"journal.log", time.UTC, []failedLogin{
+			{time.Date(2024, 10, 14, 10, 0, 1, 0, time.UTC), "admin", "203.0.113.7"},
+			{time.Date(2024, 10, 14, 10, 0, 5, 0, time.UTC), "root", "198.51.100.2"},
+		}},
+		{"journal-openssh98.log", time.UTC, []failedLogin{
+			{time.Date(2024, 10, 14, 10, 0, 1, 0, time.UTC), "admin", "203.0.113.7"},
+			{time.Date(2024, 10, 14, 10, 0, 5, 0, time.UTC), "root", "203.0.113.7"},
+			{time.Date(2024, 10, 14, 10, 1, 0, 0, time.UTC), "deploy", "2001:db8::7"},
+		}},
+		{"auth.log", time.FixedZone("", 2*3600), []failedLogin{
+			{time.Date(2024, 10, 14, 8, 0, 1, 0, time.UTC), "admin", "203.0.113.7"},
+			{time.Date(2024, 10, 14, 8, 0, 5, 0, time.UTC), "root", "198.51.100.2"},
+			{time.Date(2023, 12, 31, 21, 59, 59, 0, time.UTC), "test user", "203.0.113.7"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got, err := parseSSHDLog("journalctl", readFailedLoginsFixture(t, tt.file), tt.loc, until)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d failures, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if !got[i].time.Equal(tt.want[i].time) || got[i].user != tt.want[i].user || got[i].ip != tt.want[i].ip {
+					t.Errorf("failure %d: got %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseLastb(t *testing.T) {
+	got, err := parseLastb("lastb", readFailedLoginsFixture(t, "lastb.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []failedLogin{
+		{time.Date(2024, 10, 14, 10, 0, 1, 0, time.UTC), "admin", "203.0.113.7"},
+		{time.Date(2024, 10, 14, 9, 59, 58, 0, time.UTC), "root", "198.51.100.2"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d failures, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range got {
+		if !got[i].time.Equal(want[i].time) || got[i].user != want[i].user || got[i].ip != want[i].ip {
+			t.Errorf("failure %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseLastbLocalized(t *testing.T) {
+	_, err := parseLastb("lastb", readFailedLoginsFixture(t, "lastb-de.txt"))
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("got %v, want a parse error", err)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const CheckFilesystems = "filesystems"
+
+func init() {
+	RegisterChecker(filesystemsCheck{}, true)
+}
+
+// defaultExcludedTypes are pseudo and read-only filesystems that are either
+// always full or never fill up.
+var defaultExcludedTypes = []string{
+	"tmpfs", "devtmpfs", "overlay", "squashfs", "proc", "sysfs", "cgroup", "cgroup2",
+	"devpts", "mqueue", "debugfs", "tracefs", "securityfs", "pstore", "autofs", "efivarfs",
+	"fusectl", "configfs", "bpf", "hugetlbfs", "nsfs", "ramfs", "iso9660", "rootfs",
+}
+
+type filesystemsOptions struct {
+	// ExcludeTypes replaces defaultExcludedTypes when set, [] keeps every type.
+	ExcludeTypes []string `yaml:"exclude_types"`
+	// Include and Exclude are mountpoint patterns, e.g. "/var/lib/*".
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+	// Usage and Inodes are thresholds for the used percentage of blocks and inodes.
+	Usage  Thresholds `yaml:"usage"`
+	Inodes Thresholds `yaml:"inodes"`
+}
+
+func (o *filesystemsOptions) Validate() error {
+	if err := o.Usage.Validate(); err != nil {
+		return fmt.Errorf("usage: %v", err)
+	}
+	if err := o.Inodes.Validate(); err != nil {
+		return fmt.Errorf("inodes: %v", err)
+	}
+	return nil
+}
+
+func (o *filesystemsOptions) keep(fs Filesystem) bool {
+	excludedTypes := o.ExcludeTypes
+	if excludedTypes == nil {
+		excludedTypes = defaultExcludedTypes
+	}
+	if slices.Contains(excludedTypes, fs.FSType) {
+		return false
+	}
+	if len(o.Include) > 0 && !matchAny(o.Include, fs.Mountpoint) {
+		return false
+	}
+	return !matchAny(o.Exclude, fs.Mountpoint)
+}
+
+type Filesystem struct {
+	Mountpoint string      `json:"mountpoint"`
+	Device     string      `json:"device"`
+	FSType     string      `json:"fstype"`
+	Total      int64       `json:"total"`
+	Used       int64       `json:"used"`
+	Free       int64       `json:"free"`
+	Usage      float64     `json:"usage"`
+	Status     string      `json:"status"`
+	Inodes     *InodeUsage `json:"inodes,omitempty"`
+}
+
+// InodeUsage is left out for filesystems without a fixed inode table
+// (btrfs, vfat, ...), which report zero inodes.
+type InodeUsage struct {
+	Total  int64   `json:"total"`
+	Used   int64   `json:"used"`
+	Free   int64   `json:"free"`
+	Usage  float64 `json:"usage"`
+	Status string  `json:"status"`
+}
+
+// Filesystems is keyed by mountpoint.
+type Filesystems map[string]Filesystem
+
+func (f Filesystems) String() string {
+	mountpoints := make([]string, 0, len(f))
+	for mountpoint := range f {
+		mountpoints = append(mountpoints, mountpoint)
+	}
+	sort.Strings(mountpoints)
+
+	sb := strings.Builder{}
+	for _, mountpoint := range mountpoints {
+		fs := f[mountpoint]
+		sb.WriteString(fmt.Sprintf("\t%s (%s, %s): Total: %d, Used: %d, Free: %d, Usage: %.1f%% (%s)",
+			fs.Mountpoint, fs.Device, fs.FSType, fs.Total, fs.Used, fs.Free, fs.Usage, fs.Status))
+		if fs.Inodes != nil {
+			sb.WriteString(fmt.Sprintf(", Inodes: %d/%d, Inode Usage: %.1f%% (%s)",
+				fs.Inodes.Used, fs.Inodes.Total, fs.Inodes.Usage, fs.Inodes.Status))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+type filesystemsCheck struct{}
+
+func (filesystemsCheck) Name() string { return CheckFilesystems }
+func (filesystemsCheck) Options() any { return &filesystemsOptions{} }
+
+func (filesystemsCheck) Run(ctx context.Context, env *CheckEnv, opts any) (any, error) {
+	o := opts.(*filesystemsOptions)
+	rows, err := runDF(ctx, env.Runner, "")
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(Filesystems)
+	for _, row := range rows {
+		fs := Filesystem{
+			Device:     row.device,
+			FSType:     row.fstype,
+			Mountpoint: row.mountpoint,
+			Total:      row.total,
+			Used:       row.used,
+			Free:       row.free,
+			Usage:      row.usage(),
+		}
+		fs.Status = o.Usage.Status(fs.Usage)
+		if o.keep(fs) {
+			result[fs.Mountpoint] = fs
+		}
+	}
+
+	// Inodes are optional, e.g. BusyBox df may be built without -i.
+	inodeCmd := "LC_ALL=C df -P -T -i"
+	output, err := env.Runner.Run(ctx, inodeCmd)
+	if err != nil && len(output) == 0 {
+		log.Printf("[%s] Skipping inode usage: %v", env.Node.NodeName, err)
+		return result, nil
+	}
+	rows, err = parseDF(inodeCmd, output)
+	if err != nil {
+		log.Printf("[%s] Skipping inode usage: %v", env.Node.NodeName, err)
+		return result, nil
+	}
+	for _, row := range rows {
+		fs, ok := result[row.mountpoint]
+		if !ok || row.total == 0 {
+			continue
+		}
+		fs.Inodes = &InodeUsage{
+			Total: row.total,
+			Used:  row.used,
+			Free:  row.free,
+			Usage: row.usage(),
+		}
+		fs.Inodes.Status = o.Inodes.Status(fs.Inodes.Usage)
+		result[row.mountpoint] = fs
+	}
+	return result, nil
+}
+
+// dfVariants are tried in order: exact bytes first, then 1K blocks for df
+// builds without -B, then without -T, e.g. BusyBox.
+var dfVariants = []struct {
+	flags     string
+	blockSize int64
+}{
+	{"-T -B1", 1},
+	{"-T -k", 1024},
+	{"-k", 1024},
+}
+
+// runDF runs `df -P` with the given extra arguments and returns sizes in bytes.
+func runDF(ctx context.Context, runner Runner, args string) ([]dfRow, error) {
+	var lastErr error
+	for _, variant := range dfVariants {
+		cmd := strings.TrimSpace(fmt.Sprintf("LC_ALL=C df -P %s %s", variant.flags, args))
+		output, err := runner.Run(ctx, cmd)
+		// df exits with 1 when a single mount is unreadable, the rest is still valid.
+		if err != nil && len(output) == 0 {
+			lastErr = fmt.Errorf("failed to execute command: %w", err)
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		rows, err := parseDF(cmd, output)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for i := range rows {
+			rows[i].total *= variant.blockSize
+			rows[i].used *= variant.blockSize
+			rows[i].free *= variant.blockSize
+		}
+		return rows, nil
+	}
+	return nil, lastErr
+}
+
+type dfRow struct {
+	device     string
+	fstype     string
+	mountpoint string
+	total      int64
+	used       int64
+	free       int64
+}
+
+// usage is computed the way df does: reserved blocks count neither as used
+// nor as available.
+func (r dfRow) usage() float64 {
+	if r.used+r.free == 0 {
+		return 0
+	}
+	return float64(r.used) * 100 / float64(r.used+r.free)
+}
+
+// parseDF parses `df -P` output, either in blocks or, with -i, in inodes,
+// with or without the -T type column. The first line is the header, which
+// may be translated, so whether there is a type column is told by cmd.
+func parseDF(cmd string, output []byte) ([]dfRow, error) {
+	var rows []dfRow
+	hasType := strings.Contains(cmd, " -T")
+	for i, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if i == 0 || len(fields) == 0 {
+			continue
+		}
+		row := dfRow{device: fields[0]}
+		if hasType {
+			if len(fields) < 7 {
+				return nil, parseErrorf(cmd, "unexpected df line: %s", line)
+			}
+			row.fstype = fields[1]
+			fields = fields[1:]
+		} else if len(fields) < 6 {
+			return nil, parseErrorf(cmd, "unexpected df line: %s", line)
+		} else if !strings.HasPrefix(row.device, "/") {
+			// Without -T, pseudo filesystems are named after their type.
+			row.fstype = row.device
+		}
+		row.mountpoint = strings.Join(fields[5:], " ")
+		for j, value := range []*int64{&row.total, &row.used, &row.free} {
+			// Filesystems without inode counts show "-".
+			if fields[1+j] == "-" {
+				continue
+			}
+			n, err := strconv.ParseInt(fields[1+j], 10, 64)
+			if err != nil {
+				return nil, parseErrorf(cmd, "unexpected df line: %s", line)
+			}
+			*value = n
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, parseErrorf(cmd, "no filesystems in df output")
+	}
+	return rows, nil
+}
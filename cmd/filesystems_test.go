@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeRunner answers commands with fixtures from testdata, any other
+// command fails the way an unknown option does.
+type fakeRunner map[string]string
+
+func (r fakeRunner) Run(ctx context.Context, cmd string) ([]byte, error) {
+	file, ok := r[cmd]
+	if !ok {
+		return nil, fmt.Errorf("unexpected command %q", cmd)
+	}
+	return os.ReadFile(filepath.Join("testdata", file))
+}
+
+func compareDFRows(t *testing.T, got []dfRow, want []dfRow) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d rows, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("row %d:\ngot  %+v\nwant %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRunDF(t *testing.T) {
+	tests := []struct {
+		name   string
+		runner fakeRunner
+		want   []dfRow
+	}{
+		{"bytes", fakeRunner{"LC_ALL=C df -P -T -B1": "df/t-b1.txt"}, []dfRow{
+			{"/dev/sda1", "ext4", "/", 41077743616, 12884901888, 26083987456},
+			{"tmpfs", "tmpfs", "/dev/shm", 1022611456, 0, 1022611456},
+			{"/dev/sdb1", "xfs", "/mnt/backup disk", 107321753600, 96589578240, 10732175360},
+		}},
+		// df without -B, e.g. on older BSD-like builds.
+		{"kilobytes", fakeRunner{"LC_ALL=C df -P -T -k": "df/t-k.txt"}, []dfRow{
+			{"/dev/vda1", "ext4", "/", 40114984 * 1024, 12582912 * 1024, 25472644 * 1024},
+			{"/dev/vda15", "vfat", "/boot/efi", 106858 * 1024, 6186 * 1024, 100672 * 1024},
+		}},
+		// BusyBox without -T: pseudo filesystems are named after their type.
+		{"no type", fakeRunner{"LC_ALL=C df -P -k": "df/k.txt"}, []dfRow{
+			{"/dev/vda3", "", "/", 19523528 * 1024, 2356112 * 1024, 16150916 * 1024},
+			{"devtmpfs", "devtmpfs", "/dev", 10240 * 1024, 0, 10240 * 1024},
+			{"shm", "shm", "/dev/shm", 1011532 * 1024, 0, 1011532 * 1024},
+			{"/dev/vda1", "", "/boot", 262144 * 1024, 30720 * 1024, 231424 * 1024},
+		}},
+		{"translated header", fakeRunner{"LC_ALL=C df -P -T -k": "df/t-k-de.txt"}, []dfRow{
+			{"/dev/vda1", "ext4", "/", 40114984 * 1024, 12582912 * 1024, 25472644 * 1024},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runDF(context.Background(), tt.runner, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			compareDFRows(t, got, tt.want)
+		})
+	}
+}
+
+func TestParseDFInodes(t *testing.T) {
+	output, err := os.ReadFile(filepath.Join("testdata", "df", "t-i.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := parseDF("LC_ALL=C df -P -T -i", output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareDFRows(t, got, []dfRow{
+		{"/dev/sda1", "ext4", "/", 2621440, 312345, 2309095},
+		{"/dev/sda15", "vfat", "/boot/efi", 0, 0, 0},
+		{"overlay", "overlay", "/var/lib/docker/overlay2/abc/merged", 0, 0, 0},
+		{"/dev/sdb1", "xfs", "/mnt/backup disk", 52428800, 100000, 52328800},
+	})
+	if usage := got[0].usage(); usage < 11.9 || usage > 12 {
+		t.Errorf("got inode usage %.2f, want about 11.9", usage)
+	}
+}
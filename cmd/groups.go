@@ -0,0 +1,58 @@
+package main
+
+import (
+	"slices"
+)
+
+// GroupConfig sets checks for every node that lists the group in `groups`
+// or carries a tag of the same name, e.g. an Ansible group.
+type GroupConfig struct {
+	Name   string               `yaml:"name"`
+	Checks map[string]CheckSpec `yaml:"checks"`
+}
+
+func (c *MonitoringConfig) inGroup(name string) bool {
+	return slices.Contains(c.Groups, name) || slices.Contains(c.Tags, name)
+}
+
+// resolveChecks merges the global checks, the checks of the node's groups in
+// the order they are defined, and the node's own checks.
+func (c *Config) resolveChecks() {
+	for i := range c.Nodes {
+		node := &c.Nodes[i]
+		layers := []map[string]CheckSpec{c.Checks}
+		for _, group := range c.Groups {
+			if node.inGroup(group.Name) {
+				layers = append(layers, group.Checks)
+			}
+		}
+		layers = append(layers, node.Checks)
+		node.Checks = mergeChecks(layers...)
+	}
+}
+
+func (v *configValidator) validateGroups(config *Config) {
+	names := make(map[string]bool)
+	for i := range config.Groups {
+		group := &config.Groups[i]
+		p := at("groups", i)
+		if group.Name == "" {
+			v.addf(append(p, "name"), "is required")
+		} else if names[group.Name] {
+			v.addf(append(p, "name"), "duplicate group name %q", group.Name)
+		}
+		names[group.Name] = true
+		v.validateChecks(append(p, "checks"), group.Checks)
+	}
+	v.validateChecks(at("checks"), config.Checks)
+}
+
+// validateNodeGroups checks that groups listed by nodes are defined. Tags
+// are not checked, most of them are not meant to be groups.
+func (v *configValidator) validateNodeGroups(p []any, node *MonitoringConfig, groups []GroupConfig) {
+	for j, name := range node.Groups {
+		if !slices.ContainsFunc(groups, func(g GroupConfig) bool { return g.Name == name }) {
+			v.addf(append(p, "groups", j), "unknown group %q", name)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	HostKeyModeInsecure    = "insecure"
+	HostKeyModeKnownHosts  = "known_hosts"
+	HostKeyModeFingerprint = "fingerprint"
+	HostKeyModeTOFU        = "tofu"
+)
+
+type HostKeyConfig struct {
+	Mode        string `yaml:"mode"`
+	KnownHosts  string `yaml:"known_hosts"`
+	Fingerprint string `yaml:"fingerprint"`
+	StateFile   string `yaml:"state_file"`
+}
+
+// HostKeyMismatchError is returned when a host presents a key different
+// from the one we trust for it.
+type HostKeyMismatchError struct {
+	Host        string
+	Fingerprint string
+	Expected    []string
+}
+
+func (e *HostKeyMismatchError) Error() string {
+	return fmt.Sprintf("host key for %s changed: got %s, expected %s",
+		e.Host, e.Fingerprint, strings.Join(e.Expected, ", "))
+}
+
+// tofuMtx guards appends to TOFU state files, since nodes are checked concurrently.
+var tofuMtx sync.Mutex
+
+func defaultKnownHostsFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".ssh", "known_hosts")
+	}
+	return filepath.Join(home, ".ssh", "known_hosts")
+}
+
+func defaultTOFUStateFile() string {
+	return filepath.Join("etc", "lookout-connect", "known_hosts.tofu")
+}
+
+// defaultStateFile keeps TOFU state next to the config file unless set.
+func (h *HostKeyConfig) defaultStateFile(file string) {
+	if h.Mode == HostKeyModeTOFU && h.StateFile == "" {
+		h.StateFile = file
+	}
+}
+
+func (h *HostKeyConfig) Callback(nodeName string) (ssh.HostKeyCallback, error) {
+	switch h.Mode {
+	case "", HostKeyModeInsecure:
+		log.Printf("[%s] Warning: host key verification is disabled", nodeName)
+		return ssh.InsecureIgnoreHostKey(), nil
+	case HostKeyModeKnownHosts:
+		file := h.KnownHosts
+		if file == "" {
+			file = defaultKnownHostsFile()
+		}
+		cb, err := knownhosts.New(file)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load known_hosts %s: %v", file, err)
+		}
+		return knownHostsCallback(cb), nil
+	case HostKeyModeFingerprint:
+		if h.Fingerprint == "" {
+			return nil, fmt.Errorf("host key mode %q requires a fingerprint", h.Mode)
+		}
+		return fingerprintCallback(h.Fingerprint), nil
+	case HostKeyModeTOFU:
+		file := h.StateFile
+		if file == "" {
+			file = defaultTOFUStateFile()
+		}
+		return tofuCallback(nodeName, file), nil
+	default:
+		return nil, fmt.Errorf("unknown host key mode %q", h.Mode)
+	}
+}
+
+// knownHostsCallback converts knownhosts mismatches into HostKeyMismatchError.
+func knownHostsCallback(cb ssh.HostKeyCallback) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := cb(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) > 0 {
+			return mismatchError(hostname, key, keyErr.Want)
+		}
+		return err
+	}
+}
+
+func fingerprintCallback(fingerprint string) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		got := ssh.FingerprintSHA256(key)
+		if got == fingerprint || ssh.FingerprintLegacyMD5(key) == strings.TrimPrefix(fingerprint, "MD5:") {
+			return nil
+		}
+		return &HostKeyMismatchError{
+			Host:        hostname,
+			Fingerprint: got,
+			Expected:    []string{fingerprint},
+		}
+	}
+}
+
+// tofuCallback accepts and records the first key seen for a host, then
+// behaves like a strict known_hosts check against the recorded keys.
+func tofuCallback(nodeName string, file string) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		tofuMtx.Lock()
+		defer tofuMtx.Unlock()
+
+		if _, err := os.Stat(file); err == nil {
+			cb, err := knownhosts.New(file)
+			if err != nil {
+				return fmt.Errorf("unable to load TOFU state %s: %v", file, err)
+			}
+			err = cb(hostname, remote, key)
+			var keyErr *knownhosts.KeyError
+			if err == nil {
+				return nil
+			} else if !errors.As(err, &keyErr) {
+				return err
+			} else if len(keyErr.Want) > 0 {
+				return mismatchError(hostname, key, keyErr.Want)
+			}
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("unable to stat TOFU state %s: %v", file, err)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+			return fmt.Errorf("unable to create TOFU state directory: %v", err)
+		}
+		f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return fmt.Errorf("unable to open TOFU state %s: %v", file, err)
+		}
+		defer f.Close()
+		line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)
+		if _, err := f.WriteString(line + "\n"); err != nil {
+			return fmt.Errorf("unable to write TOFU state %s: %v", file, err)
+		}
+		log.Printf("[%s] Trusting new host key for %s: %s", nodeName, hostname, ssh.FingerprintSHA256(key))
+		return nil
+	}
+}
+
+func mismatchError(hostname string, key ssh.PublicKey, want []knownhosts.KnownKey) error {
+	expected := make([]string, 0, len(want))
+	for _, k := range want {
+		expected = append(expected, fmt.Sprintf("%s (%s:%d)", ssh.FingerprintSHA256(k.Key), k.Filename, k.Line))
+	}
+	return &HostKeyMismatchError{
+		Host:        hostname,
+		Fingerprint: ssh.FingerprintSHA256(key),
+		Expected:    expected,
+	}
+}
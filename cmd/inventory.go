@@ -0,0 +1,603 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// InventoryConfig imports nodes from files we already maintain elsewhere.
+// Imported nodes start from Defaults and never replace explicit nodes.
+type InventoryConfig struct {
+	SSHConfig []string         `yaml:"ssh_config"`
+	Ansible   []string         `yaml:"ansible"`
+	Include   []string         `yaml:"include"`
+	Defaults  MonitoringConfig `yaml:"defaults"`
+}
+
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(p, "~"))
+		}
+	}
+	return p
+}
+
+// Load reads all configured inventories and returns the imported nodes.
+func (inv *InventoryConfig) Load() ([]MonitoringConfig, error) {
+	var nodes []MonitoringConfig
+	for _, file := range inv.SSHConfig {
+		imported, err := inv.loadSSHConfig(expandHome(file))
+		if err != nil {
+			return nil, fmt.Errorf("failed to import ssh config %s: %v", file, err)
+		}
+		nodes = append(nodes, imported...)
+	}
+	for _, file := range inv.Ansible {
+		imported, err := inv.loadAnsible(expandHome(file))
+		if err != nil {
+			return nil, fmt.Errorf("failed to import ansible inventory %s: %v", file, err)
+		}
+		nodes = append(nodes, imported...)
+	}
+	return nodes, nil
+}
+
+// Merge appends imported nodes to the explicit ones, skipping names that
+// are already defined.
+func (inv *InventoryConfig) Merge(nodes []MonitoringConfig) ([]MonitoringConfig, error) {
+	imported, err := inv.Load()
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range imported {
+		if !inv.included(node.NodeName) {
+			continue
+		}
+		if slices.ContainsFunc(nodes, func(n MonitoringConfig) bool { return n.NodeName == node.NodeName }) {
+			log.Printf("Inventory node %s is already defined, keeping the explicit one", node.NodeName)
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+func (inv *InventoryConfig) included(name string) bool {
+	if len(inv.Include) == 0 {
+		return true
+	}
+	for _, pattern := range inv.Include {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (inv *InventoryConfig) newNode(name string) MonitoringConfig {
+	node := inv.Defaults
+	node.NodeName = name
+	node.Tags = slices.Clone(inv.Defaults.Tags)
+	node.Jump = slices.Clone(inv.Defaults.Jump)
+	node.Groups = slices.Clone(inv.Defaults.Groups)
+	node.Checks = maps.Clone(inv.Defaults.Checks)
+	if node.Port == 0 {
+		node.Port = 22
+	}
+	return node
+}
+
+type sshConfigBlock struct {
+	patterns []string
+	options  [][2]string
+}
+
+type sshConfigFile struct {
+	blocks []sshConfigBlock
+}
+
+func parseSSHConfig(file string, cfg *sshConfigFile, depth int) error {
+	if depth > 8 {
+		return fmt.Errorf("too many nested includes")
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if len(cfg.blocks) == 0 {
+		cfg.blocks = append(cfg.blocks, sshConfigBlock{patterns: []string{"*"}})
+	}
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sep := strings.IndexAny(line, " \t=")
+		if sep < 0 {
+			return fmt.Errorf("line %d: missing value for %s", lineNum, line)
+		}
+		key := strings.ToLower(line[:sep])
+		value := strings.Trim(strings.TrimLeft(line[sep:], " \t="), "\"")
+
+		switch key {
+		case "host":
+			cfg.blocks = append(cfg.blocks, sshConfigBlock{patterns: strings.Fields(value)})
+		case "match":
+			// Match conditions are not evaluated, options below are ignored.
+			cfg.blocks = append(cfg.blocks, sshConfigBlock{})
+		case "include":
+			for _, pattern := range strings.Fields(value) {
+				pattern = expandHome(pattern)
+				if !filepath.IsAbs(pattern) {
+					pattern = filepath.Join(filepath.Dir(file), pattern)
+				}
+				matches, err := filepath.Glob(pattern)
+				if err != nil {
+					return fmt.Errorf("line %d: bad include pattern: %v", lineNum, err)
+				}
+				for _, match := range matches {
+					if err := parseSSHConfig(match, cfg, depth+1); err != nil {
+						return fmt.Errorf("%s: %v", match, err)
+					}
+				}
+			}
+		default:
+			last := &cfg.blocks[len(cfg.blocks)-1]
+			last.options = append(last.options, [2]string{key, value})
+		}
+	}
+	return scanner.Err()
+}
+
+func (b *sshConfigBlock) matches(host string) bool {
+	matched := false
+	for _, pattern := range b.patterns {
+		negate := strings.HasPrefix(pattern, "!")
+		ok, _ := path.Match(strings.TrimPrefix(pattern, "!"), host)
+		if ok && negate {
+			return false
+		}
+		matched = matched || ok
+	}
+	return matched
+}
+
+// lookup resolves options for a host the way ssh does: the first value
+// obtained for each keyword wins.
+func (cfg *sshConfigFile) lookup(host string) map[string]string {
+	options := make(map[string]string)
+	for _, block := range cfg.blocks {
+		if !block.matches(host) {
+			continue
+		}
+		for _, opt := range block.options {
+			if _, ok := options[opt[0]]; !ok {
+				options[opt[0]] = opt[1]
+			}
+		}
+	}
+	return options
+}
+
+func (cfg *sshConfigFile) aliases() []string {
+	var aliases []string
+	for _, block := range cfg.blocks {
+		for _, pattern := range block.patterns {
+			if strings.ContainsAny(pattern, "*?!") || slices.Contains(aliases, pattern) {
+				continue
+			}
+			aliases = append(aliases, pattern)
+		}
+	}
+	return aliases
+}
+
+func (inv *InventoryConfig) loadSSHConfig(file string) ([]MonitoringConfig, error) {
+	cfg := &sshConfigFile{}
+	if err := parseSSHConfig(file, cfg, 0); err != nil {
+		return nil, err
+	}
+
+	var nodes []MonitoringConfig
+	for _, alias := range cfg.aliases() {
+		node := inv.newNode(alias)
+		if err := cfg.apply(&node, alias); err != nil {
+			return nil, fmt.Errorf("host %s: %v", alias, err)
+		}
+		jump, err := cfg.jumpChain(alias, 0)
+		if err != nil {
+			return nil, fmt.Errorf("host %s: %v", alias, err)
+		}
+		if jump != nil {
+			node.Jump = jump
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+func (cfg *sshConfigFile) apply(node *MonitoringConfig, alias string) error {
+	options := cfg.lookup(alias)
+	node.IP = alias
+	if hostName, ok := options["hostname"]; ok {
+		node.IP = strings.ReplaceAll(hostName, "%h", alias)
+	}
+	if user, ok := options["user"]; ok {
+		node.UserName = user
+	}
+	if port, ok := options["port"]; ok {
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return fmt.Errorf("bad port %q", port)
+		}
+		node.Port = p
+	}
+	if idFile, ok := options["identityfile"]; ok {
+		node.IDFile = expandHome(idFile)
+	}
+	return nil
+}
+
+// jumpChain expands ProxyJump, including the jump hosts' own ProxyJump.
+func (cfg *sshConfigFile) jumpChain(alias string, depth int) ([]JumpHost, error) {
+	if depth > 8 {
+		return nil, fmt.Errorf("ProxyJump loop")
+	}
+	proxyJump, ok := cfg.lookup(alias)["proxyjump"]
+	if !ok || strings.EqualFold(proxyJump, "none") {
+		return nil, nil
+	}
+
+	chain := []JumpHost{}
+	for _, hop := range strings.Split(proxyJump, ",") {
+		user, hostPort, found := strings.Cut(strings.TrimSpace(hop), "@")
+		if !found {
+			user, hostPort = "", user
+		}
+		host, port, hasPort := strings.Cut(hostPort, ":")
+
+		node := MonitoringConfig{Port: 22}
+		if err := cfg.apply(&node, host); err != nil {
+			return nil, fmt.Errorf("jump host %s: %v", host, err)
+		}
+		parent, err := cfg.jumpChain(host, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, parent...)
+
+		jump := JumpHost{
+			Name:     host,
+			IP:       node.IP,
+			Port:     node.Port,
+			UserName: node.UserName,
+			IDFile:   node.IDFile,
+		}
+		if user != "" {
+			jump.UserName = user
+		}
+		if hasPort {
+			p, err := strconv.Atoi(port)
+			if err != nil {
+				return nil, fmt.Errorf("jump host %s: bad port %q", host, port)
+			}
+			jump.Port = p
+		}
+		chain = append(chain, jump)
+	}
+	return chain, nil
+}
+
+type ansibleGroup struct {
+	hosts    map[string]map[string]string
+	vars     map[string]string
+	children []string
+}
+
+type ansibleInventory struct {
+	groups    map[string]*ansibleGroup
+	hostOrder []string
+}
+
+func (a *ansibleInventory) group(name string) *ansibleGroup {
+	g, ok := a.groups[name]
+	if !ok {
+		g = &ansibleGroup{
+			hosts: make(map[string]map[string]string),
+			vars:  make(map[string]string),
+		}
+		a.groups[name] = g
+	}
+	return g
+}
+
+func (a *ansibleInventory) addHost(group string, host string, vars map[string]string) {
+	g := a.group(group)
+	if _, ok := g.hosts[host]; !ok {
+		g.hosts[host] = make(map[string]string)
+	}
+	for k, v := range vars {
+		g.hosts[host][k] = v
+	}
+	if !slices.Contains(a.hostOrder, host) {
+		a.hostOrder = append(a.hostOrder, host)
+	}
+}
+
+func (inv *InventoryConfig) loadAnsible(file string) ([]MonitoringConfig, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	a := &ansibleInventory{groups: make(map[string]*ansibleGroup)}
+	ext := strings.ToLower(filepath.Ext(file))
+	if ext == ".yml" || ext == ".yaml" {
+		err = a.parseYAML(data)
+	} else {
+		err = a.parseINI(string(data))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []MonitoringConfig
+	for _, host := range a.hostOrder {
+		node := inv.newNode(host)
+		node.IP = host
+		groups := a.groupsOf(host)
+
+		// Precedence: host vars, then the host's groups, then "all".
+		vars := make(map[string]string)
+		for k, v := range a.group("all").vars {
+			vars[k] = v
+		}
+		for _, name := range groups {
+			for k, v := range a.groups[name].vars {
+				vars[k] = v
+			}
+		}
+		for _, name := range groups {
+			for k, v := range a.groups[name].hosts[host] {
+				vars[k] = v
+			}
+		}
+		for k, v := range a.group("all").hosts[host] {
+			vars[k] = v
+		}
+		if err := applyAnsibleVars(&node, vars); err != nil {
+			return nil, fmt.Errorf("host %s: %v", host, err)
+		}
+		for _, name := range groups {
+			if name != "all" && name != "ungrouped" && !slices.Contains(node.Tags, name) {
+				node.Tags = append(node.Tags, name)
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// groupsOf returns every group containing the host, parents before children.
+func (a *ansibleInventory) groupsOf(host string) []string {
+	parents := make(map[string][]string)
+	for name, g := range a.groups {
+		for _, child := range g.children {
+			parents[child] = append(parents[child], name)
+		}
+	}
+	var result []string
+	var visit func(name string, depth int)
+	visit = func(name string, depth int) {
+		if depth > 16 || slices.Contains(result, name) {
+			return
+		}
+		ps := parents[name]
+		slices.Sort(ps)
+		for _, p := range ps {
+			visit(p, depth+1)
+		}
+		result = append(result, name)
+	}
+	names := make([]string, 0, len(a.groups))
+	for name, g := range a.groups {
+		if _, ok := g.hosts[host]; ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		visit(name, 0)
+	}
+	return result
+}
+
+func applyAnsibleVars(node *MonitoringConfig, vars map[string]string) error {
+	for _, prefix := range []string{"ansible_ssh_", "ansible_"} {
+		if host, ok := vars[prefix+"host"]; ok {
+			node.IP = host
+		}
+		if user, ok := vars[prefix+"user"]; ok {
+			node.UserName = user
+		}
+		if port, ok := vars[prefix+"port"]; ok {
+			p, err := strconv.Atoi(port)
+			if err != nil {
+				return fmt.Errorf("bad %sport %q", prefix, port)
+			}
+			node.Port = p
+		}
+	}
+	if key, ok := vars["ansible_ssh_private_key_file"]; ok {
+		node.IDFile = expandHome(key)
+	}
+	return nil
+}
+
+func parseAnsibleVars(fields []string) map[string]string {
+	vars := make(map[string]string)
+	for _, field := range fields {
+		if k, v, ok := strings.Cut(field, "="); ok {
+			vars[k] = strings.Trim(v, "\"'")
+		}
+	}
+	return vars
+}
+
+// expandAnsibleRange expands numeric host patterns such as web[01:03].
+func expandAnsibleRange(host string) ([]string, error) {
+	start := strings.Index(host, "[")
+	end := strings.Index(host, "]")
+	if start < 0 || end < start {
+		return []string{host}, nil
+	}
+	from, to, ok := strings.Cut(host[start+1:end], ":")
+	if !ok {
+		return nil, fmt.Errorf("bad host range %s", host)
+	}
+	lo, err := strconv.Atoi(from)
+	if err != nil {
+		return nil, fmt.Errorf("bad host range %s", host)
+	}
+	hi, err := strconv.Atoi(to)
+	if err != nil || hi < lo {
+		return nil, fmt.Errorf("bad host range %s", host)
+	}
+	var hosts []string
+	for i := lo; i <= hi; i++ {
+		num := strconv.Itoa(i)
+		for len(num) < len(from) {
+			num = "0" + num
+		}
+		rest, err := expandAnsibleRange(host[end+1:])
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rest {
+			hosts = append(hosts, host[:start]+num+r)
+		}
+	}
+	return hosts, nil
+}
+
+func (a *ansibleInventory) parseINI(data string) error {
+	section := "ungrouped"
+	kind := "hosts"
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = line[1 : len(line)-1]
+			kind = "hosts"
+			if name, suffix, ok := strings.Cut(section, ":"); ok {
+				section, kind = name, suffix
+			}
+			a.group(section)
+			continue
+		}
+		fields := strings.Fields(line)
+		switch kind {
+		case "hosts":
+			hosts, err := expandAnsibleRange(fields[0])
+			if err != nil {
+				return fmt.Errorf("line %d: %v", i+1, err)
+			}
+			for _, host := range hosts {
+				a.addHost(section, host, parseAnsibleVars(fields[1:]))
+			}
+		case "vars":
+			k, v, ok := strings.Cut(line, "=")
+			if !ok {
+				return fmt.Errorf("line %d: expected key=value", i+1)
+			}
+			a.group(section).vars[strings.TrimSpace(k)] = strings.Trim(strings.TrimSpace(v), "\"'")
+		case "children":
+			a.group(fields[0])
+			a.group(section).children = append(a.group(section).children, fields[0])
+		default:
+			return fmt.Errorf("line %d: unknown section type %q", i+1, kind)
+		}
+	}
+	return nil
+}
+
+type ansibleYAMLGroup struct {
+	Hosts    map[string]map[string]any   `yaml:"hosts"`
+	Vars     map[string]any              `yaml:"vars"`
+	Children map[string]ansibleYAMLGroup `yaml:"children"`
+}
+
+func (a *ansibleInventory) parseYAML(data []byte) error {
+	var root map[string]ansibleYAMLGroup
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(root))
+	for name := range root {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if err := a.addYAMLGroup(name, root[name], 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *ansibleInventory) addYAMLGroup(name string, group ansibleYAMLGroup, depth int) error {
+	if depth > 16 {
+		return fmt.Errorf("group %s is nested too deep", name)
+	}
+	g := a.group(name)
+	for k, v := range group.Vars {
+		g.vars[k] = fmt.Sprint(v)
+	}
+	hosts := make([]string, 0, len(group.Hosts))
+	for host := range group.Hosts {
+		hosts = append(hosts, host)
+	}
+	slices.Sort(hosts)
+	for _, host := range hosts {
+		vars := make(map[string]string)
+		for k, v := range group.Hosts[host] {
+			vars[k] = fmt.Sprint(v)
+		}
+		expanded, err := expandAnsibleRange(host)
+		if err != nil {
+			return err
+		}
+		for _, h := range expanded {
+			a.addHost(name, h, vars)
+		}
+	}
+	children := make([]string, 0, len(group.Children))
+	for child := range group.Children {
+		children = append(children, child)
+	}
+	slices.Sort(children)
+	for _, child := range children {
+		g.children = append(g.children, child)
+		if err := a.addYAMLGroup(child, group.Children[child], depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
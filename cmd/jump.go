@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+type JumpHost struct {
+	Name     string        `yaml:"name"`
+	IP       string        `yaml:"ip"`
+	Port     int           `yaml:"port"`
+	UserName string        `yaml:"user"`
+	IDFile   string        `yaml:"id_file"`
+	Auth     AuthConfig    `yaml:"auth"`
+	HostKey  HostKeyConfig `yaml:"host_key"`
+}
+
+// sshTarget is everything needed to open one SSH connection, either to a
+// node or to one of the jump hosts in front of it.
+type sshTarget struct {
+	Name     string
+	IP       string
+	Port     int
+	UserName string
+	IDFile   string
+	Auth     AuthConfig
+	HostKey  HostKeyConfig
+}
+
+func (t *sshTarget) Address() string {
+	return fmt.Sprintf("%s:%d", t.IP, t.Port)
+}
+
+func (t *sshTarget) String() string {
+	return fmt.Sprintf("%s@%s", t.UserName, t.Address())
+}
+
+// poolKey tells hops apart that share an address but not the credentials
+// or host key policy, so they never share a pooled connection.
+func (t *sshTarget) poolKey() string {
+	return fmt.Sprintf("%s %s %+v %+v", t.String(), t.IDFile, t.Auth, t.HostKey)
+}
+
+func (c *MonitoringConfig) target() sshTarget {
+	return sshTarget{
+		IP:       c.IP,
+		Port:     c.Port,
+		UserName: c.UserName,
+		IDFile:   c.IDFile,
+		Auth:     c.Auth,
+		HostKey:  c.HostKey,
+	}
+}
+
+// hopTargets resolves the jump list of a node, filling in missing user,
+// port and key from the node itself.
+func (c *MonitoringConfig) hopTargets() []sshTarget {
+	hops := make([]sshTarget, 0, len(c.Jump))
+	for _, jump := range c.Jump {
+		hop := sshTarget{
+			Name:     jump.Name,
+			IP:       jump.IP,
+			Port:     jump.Port,
+			UserName: jump.UserName,
+			IDFile:   jump.IDFile,
+			Auth:     jump.Auth,
+			HostKey:  jump.HostKey,
+		}
+		if hop.Port == 0 {
+			hop.Port = 22
+		}
+		if hop.UserName == "" {
+			hop.UserName = c.UserName
+		}
+		if hop.IDFile == "" && hop.Auth == (AuthConfig{}) {
+			hop.IDFile = c.IDFile
+			hop.Auth = c.Auth
+		}
+		hops = append(hops, hop)
+	}
+	return hops
+}
+
+type JumpHostError struct {
+	Index int
+	Name  string
+	Hop   string
+	Err   error
+}
+
+func (e *JumpHostError) Error() string {
+	if e.Name != "" {
+		return fmt.Sprintf("jump host %d %s (%s): %v", e.Index+1, e.Name, e.Hop, e.Err)
+	}
+	return fmt.Sprintf("jump host %d (%s): %v", e.Index+1, e.Hop, e.Err)
+}
+
+func (e *JumpHostError) Unwrap() error {
+	return e.Err
+}
+
+type pooledClient struct {
+	ready  chan struct{}
+	client *ssh.Client
+	err    error
+	// abandoned is set when the dial was cut short by the context of the
+	// node dialing, which says nothing about the hop itself.
+	abandoned bool
+}
+
+// SSHPool shares jump host connections between all nodes of a single
+// InitChecks run, so a bastion in front of many nodes is dialed only once.
+type SSHPool struct {
+	mtx     sync.Mutex
+	clients map[string]*pooledClient
+	order   []string
+}
+
+func NewSSHPool() *SSHPool {
+	return &SSHPool{
+		clients: make(map[string]*pooledClient),
+	}
+}
+
+// Connect opens a connection to the node, tunneling through its jump hosts.
+func (p *SSHPool) Connect(ctx context.Context, c *MonitoringConfig) (*ssh.Client, error) {
+	var via *ssh.Client
+	chain := []string{}
+	for i, hop := range c.hopTargets() {
+		chain = append(chain, hop.poolKey())
+		client, err := p.hop(ctx, strings.Join(chain, ","), hop, c.NodeName, via)
+		if err != nil {
+			return nil, &JumpHostError{Index: i, Name: hop.Name, Hop: hop.String(), Err: err}
+		}
+		via = client
+	}
+	return createClient(ctx, c.target(), c.NodeName, via)
+}
+
+func (p *SSHPool) hop(ctx context.Context, key string, target sshTarget, nodeName string, via *ssh.Client) (*ssh.Client, error) {
+	for {
+		p.mtx.Lock()
+		entry, ok := p.clients[key]
+		if !ok {
+			entry = &pooledClient{ready: make(chan struct{})}
+			p.clients[key] = entry
+			p.order = append(p.order, key)
+		}
+		p.mtx.Unlock()
+
+		if ok {
+			select {
+			case <-entry.ready:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			if entry.abandoned {
+				p.drop(key, entry)
+				continue
+			}
+			return entry.client, entry.err
+		}
+		log.Printf("[%s] Connecting to jump host %s", nodeName, target.String())
+		entry.client, entry.err = createClient(ctx, target, nodeName, via)
+		entry.abandoned = entry.err != nil && ctx.Err() != nil
+		close(entry.ready)
+		if entry.abandoned {
+			// Nodes waiting for this hop dial it again with their own context.
+			p.drop(key, entry)
+		}
+		return entry.client, entry.err
+	}
+}
+
+// drop removes an abandoned entry, unless it was already replaced.
+func (p *SSHPool) drop(key string, entry *pooledClient) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.clients[key] != entry {
+		return
+	}
+	delete(p.clients, key)
+	p.order = slices.DeleteFunc(p.order, func(k string) bool { return k == key })
+}
+
+func (p *SSHPool) Close() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	for i := len(p.order) - 1; i >= 0; i-- {
+		entry := p.clients[p.order[i]]
+		<-entry.ready
+		if entry.client != nil {
+			entry.client.Close()
+		}
+	}
+	p.clients = make(map[string]*pooledClient)
+	p.order = nil
+}
+
+// dialSSH opens an SSH connection, directly or tunneled through via. The
+// handshake is bounded by config.Timeout and aborted when ctx is cancelled;
+// tunneled connections ignore deadlines, so both close the connection.
+func dialSSH(ctx context.Context, via *ssh.Client, address string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	var conn net.Conn
+	var err error
+	if via != nil {
+		conn, err = via.DialContext(dialCtx, "tcp", address)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open tunnel to %s: %v", address, err)
+		}
+	} else {
+		dialer := net.Dialer{}
+		conn, err = dialer.DialContext(dialCtx, "tcp", address)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	stop := context.AfterFunc(dialCtx, func() {
+		conn.Close()
+	})
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, address, config)
+	if !stop() {
+		if err == nil {
+			sshConn.Close()
+		}
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("handshake with %s cancelled: %w", address, ctx.Err())
+		}
+		return nil, fmt.Errorf("handshake with %s timed out after %s", address, config.Timeout)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type loginsOptions struct {
+	// Allowlist are IPs and CIDRs remote logins are expected from, e.g.
+	// "203.0.113.7" or "10.0.0.0/8". Without any, no login is suspicious.
+	Allowlist []string `yaml:"allowlist"`
+}
+
+func (o *loginsOptions) Validate() error {
+	for _, entry := range o.Allowlist {
+		if _, err := parseAllowlistEntry(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseAllowlistEntry(entry string) (*net.IPNet, error) {
+	if _, network, err := net.ParseCIDR(entry); err == nil {
+		return network, nil
+	}
+	ip := net.ParseIP(entry)
+	if ip == nil {
+		return nil, fmt.Errorf("allowlist entry %q is neither an IP nor a CIDR", entry)
+	}
+	bits := 8 * net.IPv4len
+	if ip.To4() == nil {
+		bits = 8 * net.IPv6len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// mark flags remote logins from outside the allowlist as suspicious, and
+// those known only by host name, which the allowlist cannot vouch for.
+func (o *loginsOptions) mark(records []UserLoginRecord) {
+	if len(o.Allowlist) == 0 {
+		return
+	}
+	networks := make([]*net.IPNet, 0, len(o.Allowlist))
+	for _, entry := range o.Allowlist {
+		network, _ := parseAllowlistEntry(entry)
+		networks = append(networks, network)
+	}
+	for i := range records {
+		if !records[i].IsRemote {
+			continue
+		}
+		records[i].Suspicious = true
+		ip := net.ParseIP(records[i].IP)
+		if ip == nil {
+			continue
+		}
+		for _, network := range networks {
+			if network.Contains(ip) {
+				records[i].Suspicious = false
+				break
+			}
+		}
+	}
+}
+
+// loginsStateVersion is bumped whenever sessionKey changes, so that the
+// sessions of an older version are recorded again instead of reported as new.
+const loginsStateVersion = 1
+
+// loginsState is the sessions seen on the previous run. Sessions no longer
+// in `last`, e.g. after wtmp was rotated, are dropped from it.
+type loginsState struct {
+	Version  int      `json:"version"`
+	Sessions []string `json:"sessions"`
+}
+
+// sessionKey identifies a session across runs; it stays the same when an
+// active session logs out.
+func sessionKey(r UserLoginRecord) string {
+	return strings.Join([]string{r.UserName, r.Terminal, r.LoginTime.UTC().Format(time.RFC3339)}, "|")
+}
+
+// markNewLogins flags sessions that were not seen on the previous run. The
+// first run only records the sessions, so the whole history is not reported
+// as new. The sessions are saved by saveLoginSessions once they are published.
+func markNewLogins(env *CheckEnv, records []UserLoginRecord) {
+	nodeName := env.Node.NodeName
+	var previous loginsState
+	found, err := env.State.Load(nodeName, CheckLogins, &previous)
+	if err != nil {
+		log.Printf("[%s] Unable to load previous login records: %v", nodeName, err)
+		return
+	}
+	if !found || previous.Version != loginsStateVersion {
+		return
+	}
+	seen := make(map[string]bool, len(previous.Sessions))
+	for _, key := range previous.Sessions {
+		seen[key] = true
+	}
+	for i := range records {
+		if !seen[sessionKey(records[i])] {
+			log.Printf("[%s] New login of %s from %s", nodeName, records[i].UserName, records[i].Source)
+			records[i].New = true
+		}
+	}
+}
+
+// saveLoginSessions records the sessions of result as seen. It is called only
+// after their events were published, so a failed publish or a run that does
+// not publish, like `check`, reports them again next time.
+func (c *MonitoringConfig) saveLoginSessions(result *MonitoringResult) {
+	if _, ok := result.Checks[CheckLogins]; !ok || result.LoginRecordsError != nil {
+		return
+	}
+	current := loginsState{Version: loginsStateVersion, Sessions: make([]string, 0, len(result.LoginRecords))}
+	for _, record := range result.LoginRecords {
+		current.Sessions = append(current.Sessions, sessionKey(record))
+	}
+	if err := c.State.Save(c.NodeName, CheckLogins, current); err != nil {
+		log.Printf("[%s] Unable to save login records: %v", c.NodeName, err)
+	}
+}
+
+// LoginEvent is published to <topic>/_lookout/new_login for every new session.
+type LoginEvent struct {
+	Node     string `json:"node"`
+	HostName string `json:"hostname"`
+	UserLoginRecord
+}
+
+func (r *MonitoringResult) newLoginEvents() []LoginEvent {
+	var events []LoginEvent
+	for _, record := range r.LoginRecords {
+		if record.New {
+			events = append(events, LoginEvent{Node: r.NodeCfgName, HostName: r.NodeName, UserLoginRecord: record})
+		}
+	}
+	return events
+}
+
+// SendNewLogins publishes an event for each new session in result. Events are
+// not retained, so subscribers do not see them again on reconnect.
+func (m *MqttConnection) SendNewLogins(ctx context.Context, result *MonitoringResult) error {
+	for _, event := range result.newLoginEvents() {
+		payload, err := json.MarshalIndent(event, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to serialize login event: %v", err)
+		}
+		if err := m.SendEvent(ctx, "new_login", string(payload), false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	isoTimePattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?([+-]\d{2}:?\d{2}|Z)$`)
+	// ttyPattern matches terminals, to tell a missing host column from a
+	// user name with spaces.
+	ttyPattern = regexp.MustCompile(`^(tty\S*|pts/\d+|console|ssh:\S+|vc/\d+|hvc\d+)$`)
+	// clockPattern matches the logout time of BusyBox last.
+	clockPattern = regexp.MustCompile(`^\d{2}:\d{2}$`)
+	// sessionDurationPattern matches (01:02) and (3+01:02).
+	sessionDurationPattern = regexp.MustCompile(`^\((?:(\d+)\+)?(\d+):(\d+)\)$`)
+)
+
+// parseLast parses `last -w -i --time-format=iso` lines such as
+//
+//	alice    pts/0        2001:db8::7      2024-10-14T10:00:01+00:00 - 2024-10-14T11:00:01+00:00  (01:00)
+//	bob      pts/1        203.0.113.7      2024-10-14T10:00:01+00:00   still logged in
+//	carol    pts/2        10.0.0.5         2024-10-14T10:00:01+00:00 - crash                      (00:10)
+//	root     tty1         0.0.0.0          2024-10-14T10:00:01+00:00   gone - no logout
+//
+// and those of BusyBox `last -W` in UTC, which has neither year nor seconds:
+//
+//	root     pts/0        192.168.1.50     Mon Oct 14 09:00 - 09:30  (0+00:30)
+//
+// The login time is found by its format rather than by column, since user
+// names may contain spaces and, without -i, the host column is empty for
+// local logins. now is the time last ran, to tell the year of BusyBox times.
+// Lines that still make no sense are logged and skipped.
+func parseLast(nodeName string, cmd string, output []byte, now time.Time) ([]UserLoginRecord, error) {
+	records := []UserLoginRecord{}
+	skipped := 0
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] == "wtmp" || fields[0] == "wtmpdb" || fields[0] == "USER" {
+			continue
+		}
+		// Reboots and shutdowns are reported by the system check.
+		if len(fields) > 1 && (fields[0] == "reboot" || fields[0] == "shutdown") && fields[1] == "system" {
+			continue
+		}
+		if fields[0] == "runlevel" {
+			continue
+		}
+		record, err := parseLastLine(fields, now)
+		if err != nil {
+			log.Printf("[%s] Skipping last line %q: %v", nodeName, line, err)
+			skipped++
+			continue
+		}
+		records = append(records, record)
+	}
+	if len(records) == 0 && skipped > 0 {
+		return nil, parseErrorf(cmd, "none of %d lines of last output could be parsed", skipped)
+	}
+	return records, nil
+}
+
+func parseLastLine(fields []string, now time.Time) (UserLoginRecord, error) {
+	i, loginTime, width := findLoginTime(fields, now)
+	if i < 2 {
+		return UserLoginRecord{}, fmt.Errorf("no login time after user and terminal")
+	}
+
+	var user, tty, host string
+	switch prefix := fields[:i]; {
+	case ttyPattern.MatchString(prefix[len(prefix)-1]) || len(prefix) == 2:
+		user, tty = strings.Join(prefix[:len(prefix)-1], " "), prefix[len(prefix)-1]
+	default:
+		user, tty, host = strings.Join(prefix[:len(prefix)-2], " "), prefix[len(prefix)-2], prefix[len(prefix)-1]
+	}
+	record := newLoginRecord(user, tty, host)
+	record.LoginTime = loginTime
+
+	rest := fields[i+width:]
+	var err error
+	switch {
+	case len(rest) >= 2 && rest[0] == "still":
+		record.Active = true
+	case len(rest) >= 1 && rest[0] == "gone":
+		// The session's process is gone without a logout record.
+	case len(rest) >= 2 && rest[0] == "-" && isoTimePattern.MatchString(rest[1]):
+		if record.LogoutTime, err = parseLogTime(rest[1]); err != nil {
+			return UserLoginRecord{}, err
+		}
+	case len(rest) >= 2 && rest[0] == "-" && (rest[1] == "crash" || rest[1] == "down" || clockPattern.MatchString(rest[1])):
+		// The system went down during the session, or BusyBox printed only
+		// the clock time of the logout, so only the session length is exact.
+		if len(rest) >= 3 {
+			duration, err := parseSessionDuration(rest[2])
+			if err != nil {
+				return UserLoginRecord{}, err
+			}
+			record.LogoutTime = loginTime.Add(duration)
+		}
+	default:
+		return UserLoginRecord{}, fmt.Errorf("unexpected session end %q", strings.Join(rest, " "))
+	}
+	return record, nil
+}
+
+// busyBoxLastLayout is the login time of BusyBox last, taking four fields.
+const busyBoxLastLayout = "Mon Jan 2 15:04"
+
+// findLoginTime returns the index of the login time in fields, the time and
+// how many fields it takes, or -1 if there is none. BusyBox times get the
+// latest year up to now in which their date falls on their weekday.
+func findLoginTime(fields []string, now time.Time) (int, time.Time, int) {
+	if i := slices.IndexFunc(fields, isoTimePattern.MatchString); i >= 0 {
+		t, err := parseLogTime(fields[i])
+		if err != nil {
+			return -1, time.Time{}, 0
+		}
+		return i, t, 1
+	}
+	for i := 0; i+4 <= len(fields); i++ {
+		t, err := time.Parse(busyBoxLastLayout, strings.Join(fields[i:i+4], " "))
+		if err != nil {
+			continue
+		}
+		// Weekdays repeat every 28 years, a day of slack allows for clock skew.
+		for year := now.Year(); year > now.Year()-28; year-- {
+			login := t.AddDate(year, 0, 0)
+			if !login.After(now.Add(24*time.Hour)) && login.Format("Mon") == fields[i] {
+				return i, login, 4
+			}
+		}
+		return -1, time.Time{}, 0
+	}
+	return -1, time.Time{}, 0
+}
+
+func parseSessionDuration(value string) (time.Duration, error) {
+	match := sessionDurationPattern.FindStringSubmatch(value)
+	if match == nil {
+		return 0, fmt.Errorf("unexpected session length %q", value)
+	}
+	days, _ := strconv.Atoi(match[1])
+	hours, _ := strconv.Atoi(match[2])
+	minutes, _ := strconv.Atoi(match[3])
+	return time.Duration(days)*24*time.Hour + time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+// newLoginRecord fills the user and where the session came from. Source is
+// the host for remote logins and the terminal for local ones. A host that
+// is a name rather than an address is remote without an IP; X displays
+// like :0 are local.
+func newLoginRecord(user string, tty string, host string) UserLoginRecord {
+	record := UserLoginRecord{UserName: user, Source: host, Terminal: tty}
+	address, _, _ := strings.Cut(strings.Trim(host, "[]"), "%")
+	if ip := net.ParseIP(address); ip != nil {
+		if !ip.IsUnspecified() {
+			record.IP = ip.String()
+			record.IsRemote = true
+		}
+	} else if host != "" && !strings.HasPrefix(host, ":") {
+		record.IsRemote = true
+	}
+	if record.Source == "" || !record.IsRemote {
+		record.Source = tty
+	}
+	return record
+}
+
+// utmp record types, see utmp(5).
+const (
+	utmpRunLevel    = 1
+	utmpBootTime    = 2
+	utmpUserProcess = 7
+	utmpDeadProcess = 8
+)
+
+var utmpdumpFieldPattern = regexp.MustCompile(`\[([^\]]*)\]`)
+
+// parseUtmpdump pairs logins and logouts from `utmpdump` lines of type, pid,
+// id, user, terminal, host, address and time, oldest first:
+//
+//	[7] [01234] [ts/0] [alice   ] [pts/0       ] [2001:db8::7         ] [2001:db8::7    ] [2024-10-14T10:00:01,123456+00:00]
+//
+// the way last does: a logout closes the session on its terminal, a reboot
+// or shutdown closes them all. Records are returned newest first.
+func parseUtmpdump(cmd string, output []byte) ([]UserLoginRecord, error) {
+	var records []UserLoginRecord
+	open := make(map[string]int)
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		matches := utmpdumpFieldPattern.FindAllStringSubmatch(line, -1)
+		if len(matches) < 8 {
+			return nil, parseErrorf(cmd, "unexpected utmpdump line: %s", line)
+		}
+		field := func(i int) string { return strings.TrimSpace(matches[i][1]) }
+		recordType, err := strconv.Atoi(field(0))
+		if err != nil {
+			return nil, parseErrorf(cmd, "unexpected utmpdump line: %s", line)
+		}
+		t, err := parseUtmpdumpTime(field(7))
+		if err != nil {
+			return nil, parseErrorf(cmd, "unexpected utmpdump time in: %s", line)
+		}
+		tty := field(4)
+
+		switch {
+		case recordType == utmpUserProcess:
+			// A login on a terminal that is still open means the logout was
+			// lost, last ends the earlier session when the new one starts.
+			if i, ok := open[tty]; ok {
+				records[i].Active = false
+				records[i].LogoutTime = t
+			}
+			// The address has the IP even where sshd put a host name in the
+			// host column, e.g. with UseDNS.
+			host := field(6)
+			if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+				host = field(5)
+			}
+			record := newLoginRecord(field(3), tty, host)
+			record.LoginTime = t
+			record.Active = true
+			records = append(records, record)
+			open[tty] = len(records) - 1
+		case recordType == utmpDeadProcess:
+			if i, ok := open[tty]; ok {
+				records[i].Active = false
+				records[i].LogoutTime = t
+				delete(open, tty)
+			}
+		case recordType == utmpBootTime, recordType == utmpRunLevel && field(3) == "shutdown":
+			for _, i := range open {
+				records[i].Active = false
+				records[i].LogoutTime = t
+			}
+			clear(open)
+		}
+	}
+	slices.Reverse(records)
+	if records == nil {
+		records = []UserLoginRecord{}
+	}
+	return records, nil
+}
+
+// parseUtmpdumpTime reads the ISO times of current utmpdump, with a comma
+// before the microseconds, and the ctime format of older ones, run in UTC,
+// which some follow with the zone name.
+func parseUtmpdumpTime(value string) (time.Time, error) {
+	if t, err := parseLogTime(strings.Replace(value, ",", ".", 1)); err == nil {
+		return t, nil
+	}
+	value = strings.Join(strings.Fields(value), " ")
+	if t, err := time.Parse("Mon Jan _2 15:04:05 2006 MST", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.ANSIC, value)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readLoginsFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func utc(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func remoteLogin(user string, tty string, source string, ip string, login string, logout string) UserLoginRecord {
+	record := UserLoginRecord{UserName: user, Terminal: tty, Source: source, IP: ip, IsRemote: true, LoginTime: utc(login)}
+	if logout == "" {
+		record.Active = true
+	} else if logout != "gone" {
+		record.LogoutTime = utc(logout)
+	}
+	return record
+}
+
+func localLogin(user string, tty string, login string, logout string) UserLoginRecord {
+	record := remoteLogin(user, tty, tty, "", login, logout)
+	record.IsRemote = false
+	return record
+}
+
+func compareLogins(t *testing.T, got []UserLoginRecord, want []UserLoginRecord) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d records, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range got {
+		g, w := got[i], want[i]
+		if g.UserName != w.UserName || g.Terminal != w.Terminal || g.Source != w.Source || g.IP != w.IP ||
+			g.IsRemote != w.IsRemote || g.Active != w.Active ||
+			!g.LoginTime.Equal(w.LoginTime) || !g.LogoutTime.Equal(w.LogoutTime) {
+			t.Errorf("record %d:\ngot  %+v\nwant %+v", i, g, w)
+		}
+	}
+}
+
+func TestParseLast(t *testing.T) {
+	now := utc("2024-10-14T13:00:00Z")
+	tests := []struct {
+		file string
+		want []UserLoginRecord
+	}{
+		{"debian.txt", []UserLoginRecord{
+			remoteLogin("alice", "pts/1", "203.0.113.7", "203.0.113.7", "2024-10-14T08:05:12Z", ""),
+			remoteLogin("bob", "pts/0", "2001:db8::7", "2001:db8::7", "2024-10-14T07:00:01Z", "2024-10-14T07:45:30Z"),
+			localLogin("root", "tty1", "2024-10-14T06:58:40Z", "gone"),
+			remoteLogin("carol", "pts/2", "10.0.0.5", "10.0.0.5", "2024-10-13T20:10:00Z", "2024-10-13T20:20:00Z"),
+			remoteLogin("dave", "pts/3", "198.51.100.20", "198.51.100.20", "2024-10-10T06:00:00Z", "2024-10-13T07:02:00Z"),
+		}},
+		{"rhel.txt", []UserLoginRecord{
+			remoteLogin("john doe", "pts/0", "10.20.30.40", "10.20.30.40", "2024-10-14T10:00:01Z", "2024-10-14T10:30:01Z"),
+			remoteLogin("admin", "pts/1", "::ffff:192.0.2.10", "192.0.2.10", "2024-10-14T09:00:01Z", ""),
+			localLogin("root", "tty1", "2024-10-14T08:00:00Z", "2024-10-14T08:59:00Z"),
+		}},
+		{"ubuntu.txt", []UserLoginRecord{
+			remoteLogin("ubuntu", "pts/0", "2001:db8:0:1::20", "2001:db8:0:1::20", "2024-10-14T12:00:00Z", ""),
+			localLogin("ubuntu", "tty2", "2024-10-14T11:00:00Z", ""),
+			remoteLogin("deploy", "pts/1", "198.51.100.7", "198.51.100.7", "2024-10-14T10:00:00Z", "2024-10-14T10:00:30Z"),
+		}},
+		// BusyBox last -W: no year or seconds, a logout only as clock time.
+		{"alpine.txt", []UserLoginRecord{
+			remoteLogin("root", "pts/1", "2001:db8::7", "2001:db8::7", "2024-10-14T12:05:00Z", ""),
+			remoteLogin("root", "pts/0", "192.168.1.50", "192.168.1.50", "2024-10-14T09:00:00Z", "2024-10-14T09:30:00Z"),
+			localLogin("root", "tty1", "2024-10-14T08:01:00Z", "2024-10-14T12:00:00Z"),
+			remoteLogin("alice", "pts/0", "203.0.113.7", "203.0.113.7", "2023-12-30T23:00:00Z", "2023-12-30T23:15:00Z"),
+		}},
+		// Without -i: host names, zones and no host column for local logins.
+		{"no-ip.txt", []UserLoginRecord{
+			remoteLogin("frank", "pts/4", "fe80::1%eth0", "fe80::1", "2024-10-14T10:00:01Z", "2024-10-14T10:05:01Z"),
+			remoteLogin("john doe", "pts/0", "jump.example.com", "", "2024-10-14T09:00:01Z", ""),
+			localLogin("root", "tty1", "2024-10-14T08:00:00Z", "gone"),
+			localLogin("mary ann", "tty2", "2024-10-14T07:00:00Z", "2024-10-14T07:30:00Z"),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got, err := parseLast("test", "last", readLoginsFixture(t, filepath.Join("last", tt.file)), now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			compareLogins(t, got, tt.want)
+		})
+	}
+}
+
+func TestParseLastGarbage(t *testing.T) {
+	_, err := parseLast("test", "last", []byte("last: unrecognized option '--time-format=iso'\nTry 'last --help'\n"), time.Now())
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("got %v, want a parse error", err)
+	}
+}
+
+func TestParseUtmpdump(t *testing.T) {
+	tests := []struct {
+		file string
+		want []UserLoginRecord
+	}{
+		// A reboot ends every open session, a new login on pts/1 ends the
+		// one of bob, which has no logout.
+		{"iso.txt", []UserLoginRecord{
+			remoteLogin("erin", "pts/0", "198.51.100.4", "198.51.100.4", "2024-10-14T12:05:00Z", ""),
+			remoteLogin("dave", "pts/2", "203.0.113.9", "203.0.113.9", "2024-10-14T11:00:00Z", "2024-10-14T12:00:00Z"),
+			remoteLogin("carol", "pts/1", "fe80::1", "fe80::1", "2024-10-14T10:20:00Z", "2024-10-14T12:00:00Z"),
+			remoteLogin("bob", "pts/1", "2001:db8::7", "2001:db8::7", "2024-10-14T10:00:00Z", "2024-10-14T10:20:00Z"),
+			remoteLogin("alice", "pts/0", "192.168.1.50", "192.168.1.50", "2024-10-14T09:00:00Z", "2024-10-14T09:30:00Z"),
+			localLogin("root", "tty1", "2024-10-14T08:01:00Z", "2024-10-14T12:00:00Z"),
+		}},
+		{"ctime.txt", []UserLoginRecord{
+			remoteLogin("bob", "pts/1", "server.example.com", "", "2024-10-14T11:30:00Z", "2024-10-14T12:00:00Z"),
+			remoteLogin("alice", "pts/0", "203.0.113.7", "203.0.113.7", "2024-10-14T10:00:01Z", "2024-10-14T11:00:01Z"),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got, err := parseUtmpdump("utmpdump", readLoginsFixture(t, filepath.Join("utmpdump", tt.file)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			compareLogins(t, got, tt.want)
+		})
+	}
+}
+
+func TestLoginsMark(t *testing.T) {
+	records := []UserLoginRecord{
+		newLoginRecord("alice", "pts/0", "10.1.2.3"),
+		newLoginRecord("bob", "pts/1", "203.0.113.7"),
+		newLoginRecord("carol", "pts/2", "jump.example.com"),
+		newLoginRecord("root", "tty1", "0.0.0.0"),
+	}
+	(&loginsOptions{Allowlist: []string{"10.0.0.0/8"}}).mark(records)
+	for i, want := range []bool{false, true, true, false} {
+		if records[i].Suspicious != want {
+			t.Errorf("%s: got suspicious %t, want %t", records[i].UserName, records[i].Suspicious, want)
+		}
+	}
+}
+
+// BusyBox rejects --time-format and has no utmpdump.
+func TestGetLoginRecordsBusyBox(t *testing.T) {
+	node := &MonitoringConfig{NodeName: "test"}
+	got, err := node.getLoginRecords(context.Background(), fakeRunner{"LC_ALL=C TZ=UTC0 last -W": "last/alpine.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 || !got[0].Active || got[1].IP != "192.168.1.50" {
+		t.Errorf("got %+v, want the 4 BusyBox sessions", got)
+	}
+}
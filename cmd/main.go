@@ -1,87 +1,196 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"slices"
 	"sync"
+	"syscall"
 	"time"
 )
 
 func main() {
-	config, err := LoadConfig()
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
-	}
-
-	log.Println("Config loaded successfully")
-
-	RunSchedule(config)
+	os.Exit(RunCLI(os.Args[1:]))
 }
 
-func RunSchedule(config Config) {
+func RunSchedule(ctx context.Context, config Config) {
 	log.Println("Running schedule!")
-	err := InitializeMQTTConnections(config.Export.MQTT)
-	if err != nil {
-		log.Printf("Warning: Failed to initialize MQTT connections: %v", err)
-		return
-	}
-	InitChecks(config)
-	log.Println("Checks finished! Cleaning up...")
-	CleanupMQTTConnections(config.Export.MQTT)
-
-	timer := time.NewTicker(config.Schedule.Interval)
-	defer timer.Stop()
 	mtx := sync.Mutex{}
-	for range timer.C {
-		if mtx.TryLock() {
-			err := InitializeMQTTConnections(config.Export.MQTT)
+	runs := sync.WaitGroup{}
+
+	// Every run works on its own copy of the config, so a reload only
+	// affects runs started after it.
+	startRun := func(config Config) {
+		if !mtx.TryLock() {
+			log.Println("Skipping schedule: last one haven't finished yet")
+			return
+		}
+		runs.Add(1)
+		go func() {
+			defer runs.Done()
+			defer mtx.Unlock()
+			err := InitializeMQTTConnections(ctx, config.Export.MQTT)
 			if err != nil {
 				log.Printf("Warning: Failed to initialize MQTT connections: %v", err)
-				mtx.Unlock()
-				continue
+				return
 			}
-			InitChecks(config)
+			InitChecks(ctx, config)
 			log.Println("Checks finished! Cleaning up...")
 			CleanupMQTTConnections(config.Export.MQTT)
-			mtx.Unlock()
-		} else {
-			log.Println("Skipping schedule: last one haven't finished yet")
+		}()
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	watcher := newConfigWatcher(config)
+	defer watcher.Stop()
+
+	startRun(config)
+	timer := time.NewTicker(config.Schedule.Interval)
+	defer timer.Stop()
+	for {
+		reload := false
+		select {
+		case <-ctx.Done():
+			shutdown(&runs, config.Schedule.GracePeriod)
+			return
+		case <-timer.C:
+			startRun(config)
+		case <-hup:
+			log.Println("Received SIGHUP")
+			watcher.changed()
+			reload = true
+		case <-watcher.C():
+			reload = watcher.changed()
+		}
+		if !reload {
+			continue
+		}
+		next, ok := ReloadConfig(ctx, config)
+		if !ok {
 			continue
 		}
+		if next.Schedule.Interval != config.Schedule.Interval {
+			timer.Reset(next.Schedule.Interval)
+		}
+		if next.Schedule.Watch != config.Schedule.Watch {
+			watcher.reset(next.Schedule.Watch)
+		}
+		config = next
 	}
 }
 
-func InitChecks(config Config) {
-	timer := time.NewTicker(config.Schedule.Splitter)
-	defer timer.Stop()
+// shutdown waits for the current run to publish what it has and disconnect.
+// Checks are already cancelled at this point, only publishing is left.
+func shutdown(runs *sync.WaitGroup, grace time.Duration) {
+	log.Printf("Shutting down, waiting up to %s for the current run to finish", grace)
+	done := make(chan struct{})
+	go func() {
+		runs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		log.Println("Shutdown complete")
+	case <-time.After(grace + time.Second):
+		log.Println("Warning: Grace period expired, exiting with the run unfinished")
+	}
+}
+
+// withGrace returns a context that stays alive for the grace period after
+// ctx is cancelled, so results already collected can still be published.
+func withGrace(ctx context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	graceCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			cancel()
+		case <-graceCtx.Done():
+		}
+	})
+	return graceCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+func InitChecks(ctx context.Context, config Config) []MonitoringResult {
+	// A zero splitter starts all nodes at once; NewTicker panics on it.
+	var splitter <-chan time.Time
+	if config.Schedule.Splitter > 0 {
+		timer := time.NewTicker(config.Schedule.Splitter)
+		defer timer.Stop()
+		splitter = timer.C
+	}
+	publishCtx, cancel := withGrace(ctx, config.Schedule.GracePeriod)
+	defer cancel()
 	resultsChan := make(chan MonitoringResult)
+	pool := NewSSHPool()
+	defer pool.Close()
 	log.Printf("Starting checks")
 
 	wgChecks := sync.WaitGroup{}
+	started := 0
+nodes:
 	for i, node := range config.Nodes {
-		wgChecks.Add(1)
-		if i != 0 {
-			<-timer.C
+		if i != 0 && splitter != nil {
 			log.Println("Waiting for next node check")
+			select {
+			case <-splitter:
+			case <-ctx.Done():
+				log.Printf("Checks cancelled, %d of %d nodes not started", len(config.Nodes)-i, len(config.Nodes))
+				break nodes
+			}
 		}
+		wgChecks.Add(1)
+		started++
 		go func(node MonitoringConfig) {
 			defer wgChecks.Done()
-			currentResult := node.PerformChecks(config.Connectivity)
+			currentResult := node.PerformChecks(ctx, config.Connectivity, pool)
 			resultsChan <- currentResult
 		}(node)
 	}
 
 	log.Printf("Waiting for results")
-	for i := 0; i < len(config.Nodes); i++ {
+	results := make([]MonitoringResult, 0, started)
+	for i := 0; i < started; i++ {
 		currentResult := <-resultsChan
 		log.Println("Received result")
+		results = append(results, currentResult)
+		if ctx.Err() != nil && currentResult.Failed() {
+			log.Printf("[%s] Not publishing result interrupted by shutdown", currentResult.NodeCfgName)
+			continue
+		}
+		published, loginsPublished := true, true
 		for _, mqtt := range config.Export.MQTT {
-			err := mqtt.SendResult(&currentResult)
+			err := mqtt.SendResult(publishCtx, &currentResult)
 			if err != nil {
 				log.Printf("Warning: Failed to send result to MQTT %s: %v", mqtt.Name, err)
+				published = false
+			}
+			if err := mqtt.SendNewLogins(publishCtx, &currentResult); err != nil {
+				log.Printf("Warning: Failed to send new logins to MQTT %s: %v", mqtt.Name, err)
+				loginsPublished = false
+			}
+		}
+		if i := slices.IndexFunc(config.Nodes, func(n MonitoringConfig) bool { return n.NodeName == currentResult.NodeCfgName }); i >= 0 {
+			if published {
+				config.Nodes[i].saveBootTime(&currentResult)
+				config.Nodes[i].saveFailedLoginsUntil(&currentResult)
+			}
+			if loginsPublished {
+				config.Nodes[i].saveLoginSessions(&currentResult)
 			}
 		}
 	}
 	close(resultsChan)
 	wgChecks.Wait()
 	log.Println("Checks finished!")
+	return results
 }
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const CheckMemory = "memory"
+
+func init() {
+	RegisterChecker(memoryCheck{}, true)
+}
+
+type memoryOptions struct {
+	Usage Thresholds `yaml:"usage"`
+	Swap  Thresholds `yaml:"swap"`
+}
+
+func (o *memoryOptions) Validate() error {
+	if err := o.Usage.Validate(); err != nil {
+		return fmt.Errorf("usage: %v", err)
+	}
+	if err := o.Swap.Validate(); err != nil {
+		return fmt.Errorf("swap: %v", err)
+	}
+	return nil
+}
+
+// MemoryInfo is in bytes. Used is what is not available to new programs,
+// i.e. total minus available, so page cache does not count as used.
+type MemoryInfo struct {
+	Total        int64   `json:"total"`
+	Available    int64   `json:"available"`
+	Used         int64   `json:"used"`
+	Free         int64   `json:"free"`
+	BuffersCache int64   `json:"buffers_cache"`
+	Usage        float64 `json:"usage"`
+	Status       string  `json:"status"`
+	SwapTotal    int64   `json:"swap_total"`
+	SwapUsed     int64   `json:"swap_used"`
+	SwapFree     int64   `json:"swap_free"`
+	SwapUsage    float64 `json:"swap_usage"`
+	SwapStatus   string  `json:"swap_status"`
+}
+
+func (m *MemoryInfo) String() string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("\tMemory: Total: %d, Available: %d, Used: %d, Buffers/Cache: %d, Usage: %.1f%% (%s)\n",
+		m.Total, m.Available, m.Used, m.BuffersCache, m.Usage, m.Status))
+	sb.WriteString(fmt.Sprintf("\tSwap: Total: %d, Used: %d, Usage: %.1f%% (%s)\n",
+		m.SwapTotal, m.SwapUsed, m.SwapUsage, m.SwapStatus))
+	return sb.String()
+}
+
+type memoryCheck struct{}
+
+func (memoryCheck) Name() string { return CheckMemory }
+func (memoryCheck) Options() any { return &memoryOptions{} }
+
+func (memoryCheck) Run(ctx context.Context, env *CheckEnv, opts any) (any, error) {
+	o := opts.(*memoryOptions)
+	cmd := "cat /proc/meminfo"
+	output, err := env.Runner.Run(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute command: %w", err)
+	}
+	info, err := parseMeminfo(cmd, output)
+	if err != nil {
+		return nil, err
+	}
+	info.Status = o.Usage.Status(info.Usage)
+	info.SwapStatus = o.Swap.Status(info.SwapUsage)
+	return info, nil
+}
+
+func parseMeminfo(cmd string, output []byte) (*MemoryInfo, error) {
+	values := make(map[string]int64)
+	for _, line := range strings.Split(string(output), "\n") {
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+		n, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, parseErrorf(cmd, "bad value for %s: %s", key, value)
+		}
+		if len(fields) > 1 && fields[1] == "kB" {
+			n *= 1024
+		}
+		values[key] = n
+	}
+	if values["MemTotal"] == 0 {
+		return nil, parseErrorf(cmd, "MemTotal missing in /proc/meminfo")
+	}
+
+	info := &MemoryInfo{
+		Total:        values["MemTotal"],
+		Free:         values["MemFree"],
+		BuffersCache: values["Buffers"] + values["Cached"] + values["SReclaimable"],
+		SwapTotal:    values["SwapTotal"],
+		SwapFree:     values["SwapFree"],
+	}
+	available, ok := values["MemAvailable"]
+	if !ok {
+		// Kernels before 3.14 have no MemAvailable.
+		available = info.Free + info.BuffersCache
+	}
+	info.Available = min(available, info.Total)
+	info.Used = info.Total - info.Available
+	info.Usage = float64(info.Used) * 100 / float64(info.Total)
+	info.SwapUsed = info.SwapTotal - info.SwapFree
+	if info.SwapTotal > 0 {
+		info.SwapUsage = float64(info.SwapUsed) * 100 / float64(info.SwapTotal)
+	}
+	return info, nil
+}
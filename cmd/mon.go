@@ -1,9 +1,10 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
-	"os"
 	"strings"
 	"time"
 
@@ -13,63 +14,100 @@ import (
 func (r *MonitoringResult) String() string {
 	builder := strings.Builder{}
 	builder.WriteString(fmt.Sprintf("Result:\nNode config name: %s\n", r.NodeCfgName))
+	if r.SSHHostKeyError != nil {
+		builder.WriteString(fmt.Sprintf("SSH Host Key Error: %s\n", r.SSHHostKeyError.Detail()))
+	}
 	if r.SSHError != nil {
-		builder.WriteString(fmt.Sprintf("SSH Error: %v\n", r.SSHError))
+		builder.WriteString(fmt.Sprintf("SSH Error: %s\n", r.SSHError.Detail()))
 		return builder.String()
 	}
-	if r.HostNameError != nil {
-		builder.WriteString(fmt.Sprintf("Host Name Error: %v\n", r.HostNameError))
-	} else {
-		builder.WriteString(fmt.Sprintf("Host Name: %s\n", r.NodeName))
-	}
-	if r.UserNameError != nil {
-		builder.WriteString(fmt.Sprintf("User Name Error: %v\n", r.UserNameError))
-	} else {
-		builder.WriteString(fmt.Sprintf("User Name: %s\n", r.UserName))
-	}
-	if r.DiskInfoError != nil {
-		builder.WriteString(fmt.Sprintf("Disk Info Error: %v\n", r.DiskInfoError))
-	} else {
-		builder.WriteString(fmt.Sprintf("Free Space: %d\n", r.FreeSpace))
-		builder.WriteString(fmt.Sprintf("Total Space: %d\n", r.TotalSpace))
-		builder.WriteString(fmt.Sprintf("Disk Usage: %f\n", r.DiskUsage))
-	}
-	if r.LoginRecordsError != nil {
-		builder.WriteString(fmt.Sprintf("Login Records Error: %v\n", r.LoginRecordsError))
-	} else {
-		builder.WriteString("Login Records:\n")
-		for _, record := range r.LoginRecords {
-			if record.IsRemote {
-				builder.WriteString(fmt.Sprintf("\tUser: %s, Active: %t, IP: %s, Login Time: %s",
-					record.UserName, record.Active, record.IP, record.LoginTime.Format(time.RFC3339)))
-			} else {
-				builder.WriteString(fmt.Sprintf("\tUser: %s, Active: %t, Source: %s, Login Time: %s",
-					record.UserName, record.Active, record.Source, record.LoginTime.Format(time.RFC3339)))
-			}
-			if !record.Active {
-				builder.WriteString(fmt.Sprintf(", Logout Time: %s\n", record.LogoutTime.Format(time.RFC3339)))
-			} else {
+	if r.ran(CheckHostName) {
+		if r.HostNameError != nil {
+			builder.WriteString(fmt.Sprintf("Host Name Error: %s\n", r.HostNameError.Detail()))
+		} else {
+			builder.WriteString(fmt.Sprintf("Host Name: %s\n", r.NodeName))
+		}
+	}
+	if r.ran(CheckUserName) {
+		if r.UserNameError != nil {
+			builder.WriteString(fmt.Sprintf("User Name Error: %s\n", r.UserNameError.Detail()))
+		} else {
+			builder.WriteString(fmt.Sprintf("User Name: %s\n", r.UserName))
+		}
+	}
+	if r.ran(CheckDisk) {
+		if r.DiskInfoError != nil {
+			builder.WriteString(fmt.Sprintf("Disk Info Error: %s\n", r.DiskInfoError.Detail()))
+		} else {
+			builder.WriteString(fmt.Sprintf("Free Space: %d\n", r.FreeSpace))
+			builder.WriteString(fmt.Sprintf("Total Space: %d\n", r.TotalSpace))
+			builder.WriteString(fmt.Sprintf("Disk Usage: %f\n", r.DiskUsage))
+		}
+	}
+	if r.ran(CheckLogins) {
+		if r.LoginRecordsError != nil {
+			builder.WriteString(fmt.Sprintf("Login Records Error: %s\n", r.LoginRecordsError.Detail()))
+		} else {
+			builder.WriteString("Login Records:\n")
+			for _, record := range r.LoginRecords {
+				if record.IP != "" {
+					builder.WriteString(fmt.Sprintf("\tUser: %s, Active: %t, IP: %s, Login Time: %s",
+						record.UserName, record.Active, record.IP, record.LoginTime.Format(time.RFC3339)))
+				} else {
+					builder.WriteString(fmt.Sprintf("\tUser: %s, Active: %t, Source: %s, Login Time: %s",
+						record.UserName, record.Active, record.Source, record.LoginTime.Format(time.RFC3339)))
+				}
+				if !record.Active {
+					builder.WriteString(fmt.Sprintf(", Logout Time: %s", record.LogoutTime.Format(time.RFC3339)))
+				}
+				if record.New {
+					builder.WriteString(", New")
+				}
+				if record.Suspicious {
+					builder.WriteString(", Suspicious")
+				}
 				builder.WriteString("\n")
 			}
 		}
 	}
-	builder.WriteString("Connectivity:\n")
-	for name, status := range r.Connectivity {
-		builder.WriteString(fmt.Sprintf("Connectivity for %s:\n", name))
-		builder.WriteString("\tTCP:\n")
-		for _, tcpStatus := range status.TCP {
-			builder.WriteString(fmt.Sprintf("\t\t%s: %s, Port: %d, Status: %t\n",
-				tcpStatus.Name, tcpStatus.RemoteIP, tcpStatus.Port, tcpStatus.Status))
+	if r.ran(CheckConnectivity) {
+		if r.ConnectivityError != nil {
+			builder.WriteString(fmt.Sprintf("Connectivity Error: %s\n", r.ConnectivityError.Detail()))
 		}
-		builder.WriteString("\tICMP:\n")
-		for _, icmpStatus := range status.ICMP {
-			builder.WriteString(fmt.Sprintf("\t\t%s: %s, Status: %t, Latency: %s\n",
-				icmpStatus.Name, icmpStatus.RemoteIP, icmpStatus.Status, icmpStatus.Latency))
+		builder.WriteString("Connectivity:\n")
+		for name, status := range r.Connectivity {
+			builder.WriteString(fmt.Sprintf("Connectivity for %s:\n", name))
+			builder.WriteString("\tTCP:\n")
+			for _, tcpStatus := range status.TCP {
+				builder.WriteString(fmt.Sprintf("\t\t%s: %s, Port: %d, Status: %t\n",
+					tcpStatus.Name, tcpStatus.RemoteIP, tcpStatus.Port, tcpStatus.Status))
+			}
+			builder.WriteString("\tICMP:\n")
+			for _, icmpStatus := range status.ICMP {
+				builder.WriteString(fmt.Sprintf("\t\t%s: %s, Status: %t, Latency: %s\n",
+					icmpStatus.Name, icmpStatus.RemoteIP, icmpStatus.Status, icmpStatus.Latency))
+			}
+			builder.WriteString("\tHTTP:\n")
+			for _, httpStatus := range status.HTTP {
+				builder.WriteString(fmt.Sprintf("\t\t%s: %s, Status: %t, Code: %d\n",
+					httpStatus.Name, httpStatus.Host, httpStatus.Status, httpStatus.Code))
+			}
+		}
+	}
+	// Checks without legacy fields print their own results.
+	for _, registered := range checkers {
+		name := registered.checker.Name()
+		check, ok := r.Checks[name]
+		if _, legacy := registered.checker.(legacyChecker); legacy || !ok {
+			continue
+		}
+		if check.Error != nil {
+			builder.WriteString(fmt.Sprintf("Check %s Error: %s\n", name, check.Error.Detail()))
 		}
-		builder.WriteString("\tHTTP:\n")
-		for _, httpStatus := range status.HTTP {
-			builder.WriteString(fmt.Sprintf("\t\t%s: %s, Status: %t, Code: %d\n",
-				httpStatus.Name, httpStatus.Host, httpStatus.Status, httpStatus.Code))
+		if stringer, ok := check.Result.(fmt.Stringer); ok {
+			builder.WriteString(fmt.Sprintf("Check %s:\n%s", name, stringer.String()))
+		} else if check.Result != nil {
+			builder.WriteString(fmt.Sprintf("Check %s: %+v\n", name, check.Result))
 		}
 	}
 	builder.WriteString(fmt.Sprintf("Check Time: %s - %s (%f seconds)\n",
@@ -77,66 +115,102 @@ func (r *MonitoringResult) String() string {
 	return builder.String()
 }
 
-func createClient(ip string, port int, user string, idFile string) (*ssh.Client, error) {
-	log.Printf("Creating client to %s:%d", ip, port)
-	key, err := os.ReadFile(idFile)
+func (r *MonitoringResult) ran(check string) bool {
+	_, ok := r.Checks[check]
+	return ok
+}
+
+// Failed reports whether the node could not be reached or any check errored.
+func (r *MonitoringResult) Failed() bool {
+	if r.SSHError != nil {
+		return true
+	}
+	for _, check := range r.Checks {
+		if check.Error != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func createClient(ctx context.Context, target sshTarget, nodeName string, via *ssh.Client) (*ssh.Client, error) {
+	log.Printf("Creating client to %s", target.Address())
+	hostKeyCallback, err := target.HostKey.Callback(nodeName)
 	if err != nil {
-		log.Printf("Unable to read private key: %v", err)
-		return nil, fmt.Errorf("unable to read private key: %v", err)
+		log.Printf("Unable to set up host key verification: %v", err)
+		return nil, fmt.Errorf("unable to set up host key verification: %v", err)
 	}
 
-	signer, err := ssh.ParsePrivateKey(key)
+	auth, err := target.Auth.Prepare(target.IDFile)
 	if err != nil {
-		log.Printf("Unable to parse private key: %v", err)
-		return nil, fmt.Errorf("unable to parse private key: %v", err)
+		log.Printf("Unable to set up authentication: %v", err)
+		return nil, &AuthError{Err: fmt.Errorf("unable to set up authentication: %w", err)}
+	}
+	defer auth.Close()
+	for _, methodErr := range auth.errors {
+		log.Printf("[%s] Skipping %s authentication: %v", nodeName, methodErr.Method, methodErr.Err)
 	}
 
 	config := &ssh.ClientConfig{
-		User: user,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		User:            target.UserName,
+		Auth:            auth.Methods(),
+		HostKeyCallback: hostKeyCallback,
 		Timeout:         30 * time.Second,
 	}
 
-	client, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", ip, port), config)
+	client, err := dialSSH(ctx, via, target.Address(), config)
 	if err != nil {
 		log.Printf("Unable to connect: %v", err)
-		return nil, fmt.Errorf("unable to connect: %v", err)
+		return nil, fmt.Errorf("unable to connect: %w", auth.Wrap(err))
 	}
 
 	return client, nil
 }
 
-func (c *MonitoringConfig) PerformChecks(connConfig ConnectivityConfig) MonitoringResult {
+func (c *MonitoringConfig) PerformChecks(ctx context.Context, connConfig ConnectivityConfig, pool *SSHPool) MonitoringResult {
 	log.Printf("Performing checks for %s", c.NodeName)
 	result := MonitoringResult{
 		NodeCfgName:    c.NodeName,
 		CheckStartTime: time.Now(),
 	}
 
-	client, err := createClient(c.IP, c.Port, c.UserName, c.IDFile)
+	// The node budget covers connecting as well, so a stuck jump host
+	// cannot hold the node past it either.
+	ctx, cancel := context.WithTimeout(ctx, c.Timeouts.NodeBudget())
+	defer cancel()
+
+	client, err := pool.Connect(ctx, c)
 	if err != nil {
-		result.SSHError = err
+		var mismatch *HostKeyMismatchError
+		if errors.As(err, &mismatch) {
+			log.Printf("[%s] Host key mismatch: %v", c.NodeName, mismatch)
+			result.SSHHostKeyError = newCheckError(mismatch, ErrorCategoryHostKey)
+		}
+		result.SSHError = newCheckError(err, ErrorCategoryConnect)
 		return result
 	}
 	defer client.Close()
 
-	log.Printf("[%s] Getting node name", c.NodeName)
-	result.NodeName, result.HostNameError = c.getNodeName(client)
-
-	log.Printf("[%s] Getting user name", c.NodeName)
-	result.UserName, result.UserNameError = c.getUserName(client)
-
-	log.Printf("[%s] Getting disk info", c.NodeName)
-	result.FreeSpace, result.TotalSpace, result.DiskUsage, result.DiskInfoError = c.getDiskInfo(client)
-
-	log.Printf("[%s] Getting login records", c.NodeName)
-	result.LoginRecords, result.LoginRecordsError = c.getLoginRecords(client)
-
-	log.Printf("[%s] Getting connectivity", c.NodeName)
-	result.Connectivity, result.ConnectivityError = c.getConnectivity(client, connConfig.TCP, connConfig.ICMP, connConfig.HTTP)
+	env := &CheckEnv{Node: c, Runner: sshRunner{client: client}, Connectivity: connConfig, State: c.State}
+	result.Checks = make(map[string]CheckResult)
+	for _, check := range c.enabledChecks() {
+		name := check.checker.Name()
+		log.Printf("[%s] Running check %s", c.NodeName, name)
+		start := time.Now()
+		var value any
+		checkErr := c.runCheck(ctx, name, func(ctx context.Context) (err error) {
+			value, err = check.checker.Run(ctx, env, check.options)
+			return err
+		})
+		result.Checks[name] = CheckResult{
+			Result:   value,
+			Error:    checkErr,
+			Duration: time.Since(start).Seconds(),
+		}
+		if legacy, ok := check.checker.(legacyChecker); ok {
+			legacy.setLegacy(&result, value, checkErr)
+		}
+	}
 
 	result.CheckEndTime = time.Now()
 	result.CheckDuration = result.CheckEndTime.Sub(result.CheckStartTime).Seconds()
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"time"
@@ -9,19 +10,18 @@ import (
 )
 
 type MqttConnection struct {
-	Name     string `yaml:"name"`
-	Broker   string `yaml:"broker"`
-	Topic    string `yaml:"topic"`
-	ClientID string `yaml:"client_id"`
-	Qos      int    `yaml:"qos"`
-	Retain   bool   `yaml:"retain"`
-	Username string
-	Password string
-	Client   mqtt.Client
+	Name     string      `yaml:"name"`
+	Broker   string      `yaml:"broker"`
+	Topic    string      `yaml:"topic"`
+	ClientID string      `yaml:"client_id" default:"lookout-connect"`
+	Qos      int         `yaml:"qos"`
+	Retain   bool        `yaml:"retain"`
+	Username string      `yaml:"-"`
+	Password string      `yaml:"-"`
+	Client   mqtt.Client `yaml:"-"`
 }
 
-func (m *MqttConnection) Initialize() error {
-
+func (m *MqttConnection) Initialize(ctx context.Context) error {
 	opts := mqtt.NewClientOptions()
 	opts.AddBroker(m.Broker)
 	opts.SetClientID(m.ClientID)
@@ -37,15 +37,25 @@ func (m *MqttConnection) Initialize() error {
 
 	m.Client = mqtt.NewClient(opts)
 
-	if token := m.Client.Connect(); token.Wait() && token.Error() != nil {
-		return fmt.Errorf("failed to connect to MQTT broker %s: %v", m.Broker, token.Error())
+	if err := waitToken(ctx, m.Client.Connect()); err != nil {
+		return fmt.Errorf("failed to connect to MQTT broker %s: %w", m.Broker, err)
 	}
 
 	log.Printf("Successfully connected to MQTT broker: %s", m.Broker)
 	return nil
 }
 
-func (m *MqttConnection) SendResult(result *MonitoringResult) error {
+// waitToken waits for an MQTT operation unless ctx is cancelled first.
+func waitToken(ctx context.Context, token mqtt.Token) error {
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *MqttConnection) SendResult(ctx context.Context, result *MonitoringResult) error {
 	log.Printf("[%s] Sending result to MQTT (%s)", result.NodeCfgName, m.Name)
 	if m.Client == nil {
 		return fmt.Errorf("MQTT client not initialized")
@@ -57,14 +67,28 @@ func (m *MqttConnection) SendResult(result *MonitoringResult) error {
 	}
 	log.Printf("[%s] Publishing result to MQTT (%s)", result.NodeCfgName, m.Name)
 	token := m.Client.Publish(fmt.Sprintf("%s/%s", m.Topic, result.NodeCfgName), byte(m.Qos), m.Retain, jsonData)
-	if token.Wait() && token.Error() != nil {
-		return fmt.Errorf("failed to publish message to topic %s: %v", m.Topic, token.Error())
+	if err := waitToken(ctx, token); err != nil {
+		return fmt.Errorf("failed to publish message to topic %s: %w", m.Topic, err)
 	}
 
 	log.Printf("[%s] Successfully published monitoring result to MQTT topic: %s", result.NodeCfgName, m.Topic)
 	return nil
 }
 
+// SendEvent publishes a payload that is not a node result to <topic>/_lookout/<name>.
+func (m *MqttConnection) SendEvent(ctx context.Context, name string, payload string, retain bool) error {
+	if m.Client == nil {
+		return fmt.Errorf("MQTT client not initialized")
+	}
+	topic := fmt.Sprintf("%s/_lookout/%s", m.Topic, name)
+	token := m.Client.Publish(topic, byte(m.Qos), retain, payload)
+	if err := waitToken(ctx, token); err != nil {
+		return fmt.Errorf("failed to publish message to topic %s: %w", topic, err)
+	}
+	log.Printf("Published %s event to MQTT topic: %s", name, topic)
+	return nil
+}
+
 func (m *MqttConnection) Close() error {
 	if m.Client != nil {
 		m.Client.Disconnect(250)
@@ -72,10 +96,10 @@ func (m *MqttConnection) Close() error {
 	return nil
 }
 
-func InitializeMQTTConnections(mqttConnections []MqttConnection) error {
+func InitializeMQTTConnections(ctx context.Context, mqttConnections []MqttConnection) error {
 	log.Printf("Initializing %d MQTT connections", len(mqttConnections))
 	for i := range len(mqttConnections) {
-		if err := mqttConnections[i].Initialize(); err != nil {
+		if err := mqttConnections[i].Initialize(ctx); err != nil {
 			log.Printf("Failed to initialize MQTT connection %s: %v", mqttConnections[i].Name, err)
 			continue
 		}
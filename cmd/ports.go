@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const CheckPorts = "ports"
+
+func init() {
+	RegisterChecker(portsCheck{}, false)
+}
+
+type portsOptions struct {
+	// Expected are "[address:]port[/proto]" entries, e.g. "22/tcp" or
+	// "127.0.0.1:5432". Without any, listeners are only listed.
+	Expected []string `yaml:"expected"`
+	// IgnoreLoopback keeps listeners on 127.0.0.0/8 and ::1 out of unexpected.
+	IgnoreLoopback bool `yaml:"ignore_loopback" default:"true"`
+}
+
+func (o *portsOptions) Validate() error {
+	for _, entry := range o.Expected {
+		if _, err := parsePortSpec(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// portSpec is a parsed expected entry, empty fields match anything.
+type portSpec struct {
+	entry   string
+	proto   string
+	address string
+	port    int
+}
+
+func parsePortSpec(entry string) (portSpec, error) {
+	spec := portSpec{entry: entry}
+	rest := entry
+	if before, proto, found := strings.Cut(rest, "/"); found {
+		if proto != "tcp" && proto != "udp" {
+			return spec, fmt.Errorf("expected port %q: protocol must be tcp or udp", entry)
+		}
+		spec.proto = proto
+		rest = before
+	}
+	port := rest
+	if i := strings.LastIndex(rest, ":"); i >= 0 {
+		spec.address = strings.Trim(rest[:i], "[]")
+		port = rest[i+1:]
+		if net.ParseIP(spec.address) == nil && spec.address != "*" {
+			return spec, fmt.Errorf("expected port %q: invalid address %q", entry, spec.address)
+		}
+		if spec.address == "*" {
+			spec.address = ""
+		}
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || !validPort(n) {
+		return spec, fmt.Errorf("expected port %q: port must be between 1 and 65535", entry)
+	}
+	spec.port = n
+	return spec, nil
+}
+
+func (s portSpec) matches(l Listener) bool {
+	if s.port != l.Port || (s.proto != "" && s.proto != l.Proto) {
+		return false
+	}
+	return s.address == "" || net.ParseIP(s.address).Equal(net.ParseIP(l.Address))
+}
+
+// PortsInfo lists every listening socket. Status is critical when any
+// listener is unexpected or an expected one is missing.
+type PortsInfo struct {
+	Listeners  []Listener `json:"listeners"`
+	Unexpected []Listener `json:"unexpected"`
+	Missing    []string   `json:"missing"`
+	Status     string     `json:"status"`
+}
+
+// Listener is a listening socket. Process and PID are only known when the
+// SSH user may see the owning process, e.g. root.
+type Listener struct {
+	Proto     string `json:"proto"`
+	Address   string `json:"address"`
+	Interface string `json:"interface,omitempty"`
+	Port      int    `json:"port"`
+	Process   string `json:"process,omitempty"`
+	PID       int    `json:"pid,omitempty"`
+}
+
+func (l Listener) String() string {
+	address := l.Address
+	if strings.Contains(address, ":") {
+		address = "[" + address + "]"
+	}
+	if l.Interface != "" {
+		address += "%" + l.Interface
+	}
+	s := fmt.Sprintf("%s %s:%d", l.Proto, address, l.Port)
+	if l.Process != "" {
+		s += fmt.Sprintf(" (%s, pid %d)", l.Process, l.PID)
+	}
+	return s
+}
+
+func (l Listener) loopback() bool {
+	ip := net.ParseIP(l.Address)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (p *PortsInfo) String() string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("\tStatus: %s, Listeners: %d\n", p.Status, len(p.Listeners)))
+	for _, l := range p.Listeners {
+		sb.WriteString(fmt.Sprintf("\t%s\n", l))
+	}
+	for _, l := range p.Unexpected {
+		sb.WriteString(fmt.Sprintf("\tUnexpected: %s\n", l))
+	}
+	for _, entry := range p.Missing {
+		sb.WriteString(fmt.Sprintf("\tMissing: %s\n", entry))
+	}
+	return sb.String()
+}
+
+type portsCheck struct{}
+
+func (portsCheck) Name() string { return CheckPorts }
+func (portsCheck) Options() any { return &portsOptions{} }
+
+func (portsCheck) Run(ctx context.Context, env *CheckEnv, opts any) (any, error) {
+	o := opts.(*portsOptions)
+	listeners, err := runSS(ctx, env.Runner)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &PortsInfo{Listeners: listeners, Unexpected: []Listener{}, Missing: []string{}, Status: StatusOK}
+	if len(o.Expected) == 0 {
+		return info, nil
+	}
+	specs := make([]portSpec, 0, len(o.Expected))
+	for _, entry := range o.Expected {
+		spec, _ := parsePortSpec(entry)
+		specs = append(specs, spec)
+	}
+	found := make([]bool, len(specs))
+	for _, l := range listeners {
+		expected := false
+		for i, spec := range specs {
+			if spec.matches(l) {
+				found[i] = true
+				expected = true
+			}
+		}
+		if !expected && !(o.IgnoreLoopback && l.loopback()) {
+			info.Unexpected = append(info.Unexpected, l)
+		}
+	}
+	for i, spec := range specs {
+		if !found[i] {
+			info.Missing = append(info.Missing, spec.entry)
+		}
+	}
+	if len(info.Unexpected) > 0 || len(info.Missing) > 0 {
+		info.Status = StatusCritical
+	}
+	return info, nil
+}
+
+// ssVariants are tried in order: older ss has no -H and prints a header,
+// which parseSS skips.
+var ssVariants = []string{"ss -tulpnH", "ss -tulpn"}
+
+func runSS(ctx context.Context, runner Runner) ([]Listener, error) {
+	var lastErr error
+	for _, cmd := range ssVariants {
+		output, err := runner.Run(ctx, cmd)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to execute command: %w", err)
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		return parseSS(cmd, output)
+	}
+	return nil, lastErr
+}
+
+var ssProcessPattern = regexp.MustCompile(`\(\("([^"]*)",pid=(\d+)`)
+
+// parseSS parses `ss -tulpnH` lines such as
+//
+//	tcp   LISTEN 0      4096          [::]:22          [::]:*    users:(("sshd",pid=812,fd=4))
+//	udp   UNCONN 0      0      127.0.0.53%lo:53     0.0.0.0:*    users:(("systemd-resolve",pid=600,fd=13))
+func parseSS(cmd string, output []byte) ([]Listener, error) {
+	listeners := []Listener{}
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] == "Netid" {
+			continue
+		}
+		if len(fields) < 5 {
+			return nil, parseErrorf(cmd, "unexpected ss line: %s", line)
+		}
+		local := fields[4]
+		i := strings.LastIndex(local, ":")
+		if i < 0 {
+			return nil, parseErrorf(cmd, "unexpected ss line: %s", line)
+		}
+		port, err := strconv.Atoi(local[i+1:])
+		if err != nil {
+			return nil, parseErrorf(cmd, "unexpected ss line: %s", line)
+		}
+		address, iface, _ := strings.Cut(local[:i], "%")
+		address = strings.Trim(address, "[]")
+		if address == "*" {
+			address = "0.0.0.0"
+		}
+		l := Listener{Proto: fields[0], Address: address, Interface: iface, Port: port}
+		if match := ssProcessPattern.FindStringSubmatch(line); match != nil {
+			l.Process = match[1]
+			l.PID, _ = strconv.Atoi(match[2])
+		}
+		listeners = append(listeners, l)
+	}
+	sort.SliceStable(listeners, func(i, j int) bool {
+		a, b := listeners[i], listeners[j]
+		if a.Proto != b.Proto {
+			return a.Proto < b.Proto
+		}
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		return a.Address < b.Address
+	})
+	return listeners, nil
+}
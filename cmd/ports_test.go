@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// Older ss rejects -H, so the listing with a header is parsed instead.
+func TestRunSSHeader(t *testing.T) {
+	got, err := runSS(context.Background(), fakeRunner{"ss -tulpn": "ss/header.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Listener{
+		{Proto: "tcp", Address: "0.0.0.0", Port: 22, Process: "sshd", PID: 812},
+		{Proto: "tcp", Address: "::", Port: 22, Process: "sshd", PID: 812},
+		{Proto: "udp", Address: "127.0.0.53", Interface: "lo", Port: 53, Process: "systemd-resolve", PID: 600},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d listeners, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("listener %d:\ngot  %+v\nwant %+v", i, got[i], want[i])
+		}
+	}
+}
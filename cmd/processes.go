@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const CheckProcesses = "processes"
+
+func init() {
+	RegisterChecker(processesCheck{}, false)
+}
+
+type processesOptions struct {
+	Watch []processWatch `yaml:"watch"`
+}
+
+// processWatch selects processes by any combination of their name (comm),
+// a regexp on their full command line and their user.
+type processWatch struct {
+	Name    string `yaml:"name"`
+	Command string `yaml:"command"`
+	Pattern string `yaml:"pattern"`
+	User    string `yaml:"user"`
+	// Min defaults to 1, Max of 0 is no upper bound.
+	Min *int `yaml:"min"`
+	Max int  `yaml:"max"`
+}
+
+func (w processWatch) min() int {
+	if w.Min == nil {
+		return 1
+	}
+	return *w.Min
+}
+
+func (o *processesOptions) Validate() error {
+	names := make(map[string]bool)
+	for i, w := range o.Watch {
+		if w.Name == "" {
+			return fmt.Errorf("watch[%d]: name is required", i)
+		}
+		if names[w.Name] {
+			return fmt.Errorf("watch[%d]: duplicate name %q", i, w.Name)
+		}
+		names[w.Name] = true
+		if w.Command == "" && w.Pattern == "" && w.User == "" {
+			return fmt.Errorf("watch[%d]: one of command, pattern or user is required", i)
+		}
+		if _, err := regexp.Compile(w.Pattern); err != nil {
+			return fmt.Errorf("watch[%d]: invalid pattern: %v", i, err)
+		}
+		if w.min() < 0 || w.Max < 0 || (w.Max > 0 && w.min() > w.Max) {
+			return fmt.Errorf("watch[%d]: min %d and max %d do not make a range", i, w.min(), w.Max)
+		}
+	}
+	return nil
+}
+
+// Processes is keyed by the watch name. Status is critical when the number
+// of matching processes is outside min and max.
+type Processes map[string]ProcessGroup
+
+type ProcessGroup struct {
+	Count     int       `json:"count"`
+	Min       int       `json:"min"`
+	Max       int       `json:"max,omitempty"`
+	Status    string    `json:"status"`
+	Processes []Process `json:"processes"`
+}
+
+// Process is a single process from ps. CPU is the average since the process
+// started, as ps reports it, or 0 with BusyBox ps, and RSS is in bytes.
+type Process struct {
+	PID       int       `json:"pid"`
+	User      string    `json:"user"`
+	Command   string    `json:"command"`
+	Args      string    `json:"args"`
+	CPU       float64   `json:"cpu"`
+	RSS       int64     `json:"rss"`
+	StartTime time.Time `json:"start_time"`
+}
+
+func (p Processes) String() string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sb := strings.Builder{}
+	for _, name := range names {
+		group := p[name]
+		sb.WriteString(fmt.Sprintf("\t%s: %d running (%s)\n", name, group.Count, group.Status))
+		for _, proc := range group.Processes {
+			sb.WriteString(fmt.Sprintf("\t\tPID: %d, User: %s, CPU: %.1f%%, RSS: %d, Started: %s, Command: %s\n",
+				proc.PID, proc.User, proc.CPU, proc.RSS, proc.StartTime.Format(time.RFC3339), proc.Args))
+		}
+	}
+	return sb.String()
+}
+
+type processesCheck struct{}
+
+func (processesCheck) Name() string { return CheckProcesses }
+func (processesCheck) Options() any { return &processesOptions{} }
+
+func (processesCheck) Run(ctx context.Context, env *CheckEnv, opts any) (any, error) {
+	o := opts.(*processesOptions)
+	procs, err := runPS(ctx, env.Runner)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(Processes)
+	var problems []string
+	for _, w := range o.Watch {
+		pattern := regexp.MustCompile(w.Pattern)
+		group := ProcessGroup{Min: w.min(), Max: w.Max, Status: StatusOK, Processes: []Process{}}
+		for _, proc := range procs {
+			if (w.Command == "" || proc.Command == w.Command) &&
+				(w.User == "" || proc.User == w.User) &&
+				(w.Pattern == "" || pattern.MatchString(proc.Args)) {
+				group.Processes = append(group.Processes, proc)
+			}
+		}
+		group.Count = len(group.Processes)
+		if group.Count < group.Min {
+			group.Status = StatusCritical
+			problems = append(problems, fmt.Sprintf("%s: %d running, expected at least %d", w.Name, group.Count, group.Min))
+		} else if group.Max > 0 && group.Count > group.Max {
+			group.Status = StatusCritical
+			problems = append(problems, fmt.Sprintf("%s: %d running, expected at most %d", w.Name, group.Count, group.Max))
+		}
+		result[w.Name] = group
+	}
+	if len(problems) > 0 {
+		return result, failedErrorf("%s", strings.Join(problems, "; "))
+	}
+	return result, nil
+}
+
+// psVariants are tried in order: procps first, then BusyBox, which knows
+// neither -e, column widths, pcpu nor etimes and truncates user names.
+var psVariants = []struct {
+	cmd    string
+	hasCPU bool
+}{
+	{"ps -eo pid=,comm=; echo --; ps -eo pid=,user:32=,pcpu=,rss=,etimes=,args=", true},
+	{"ps -o pid=,comm=; echo --; ps -o pid=,user=,rss=,etime=,args=", false},
+}
+
+// runPS lists all processes. comm and args may both contain spaces, so they
+// are listed separately and each one last on its line.
+func runPS(ctx context.Context, runner Runner) ([]Process, error) {
+	var lastErr error
+	for _, variant := range psVariants {
+		output, err := runner.Run(ctx, variant.cmd)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to execute command: %w", err)
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		procs, err := parsePS(variant.cmd, output, variant.hasCPU, time.Now())
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return procs, nil
+	}
+	return nil, lastErr
+}
+
+// parsePS parses the two ps listings of runPS, joined by pid. The start time is derived from the elapsed time. Without hasCPU the
+// listing has no pcpu column and CPU is left at 0.
+func parsePS(cmd string, output []byte, hasCPU bool, now time.Time) ([]Process, error) {
+	commands, listing, found := strings.Cut(string(output), "\n--\n")
+	if !found {
+		return nil, parseErrorf(cmd, "missing separator between ps listings")
+	}
+	names := make(map[int]string)
+	for _, line := range strings.Split(commands, "\n") {
+		pid, name, _ := strings.Cut(strings.TrimSpace(line), " ")
+		if n, err := strconv.Atoi(pid); err == nil {
+			names[n] = strings.TrimSpace(name)
+		}
+	}
+
+	columns := 5
+	if !hasCPU {
+		columns = 4
+	}
+	var procs []Process
+	for _, line := range strings.Split(listing, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < columns+1 {
+			return nil, parseErrorf(cmd, "unexpected ps line: %s", line)
+		}
+		var cpu float64
+		var err2 error
+		if hasCPU {
+			cpu, err2 = strconv.ParseFloat(fields[2], 64)
+		}
+		pid, err1 := strconv.Atoi(fields[0])
+		rss, err3 := parseRSS(fields[columns-2])
+		elapsed, err4 := parseElapsed(fields[columns-1])
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+			return nil, parseErrorf(cmd, "unexpected ps line: %s", line)
+		}
+		args := strings.Join(fields[columns:], " ")
+		// Leave out ps and the shell running it, they would match patterns.
+		if strings.Contains(args, "o pid=,") {
+			continue
+		}
+		procs = append(procs, Process{
+			PID:       pid,
+			User:      fields[1],
+			Command:   names[pid],
+			Args:      args,
+			CPU:       cpu,
+			RSS:       rss,
+			StartTime: now.Add(-elapsed).Truncate(time.Second),
+		})
+	}
+	return procs, nil
+}
+
+// parseElapsed parses seconds, as etimes prints them, or [[dd-]hh:]mm:ss,
+// as etime does. BusyBox etime is only minutes and seconds.
+func parseElapsed(value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	var days int64
+	if d, rest, found := strings.Cut(value, "-"); found {
+		n, err := strconv.ParseInt(d, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("unexpected elapsed time %q", value)
+		}
+		days, value = n, rest
+	}
+	parts := strings.Split(value, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("unexpected elapsed time %q", value)
+	}
+	var seconds int64
+	for _, part := range parts {
+		n, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("unexpected elapsed time %q", value)
+		}
+		seconds = seconds*60 + n
+	}
+	return time.Duration(days*86400+seconds) * time.Second, nil
+}
+
+// rssUnits scales the suffixes of BusyBox ps, which prints at most four
+// characters and so shows larger values as e.g. 12m or 1.2g.
+var rssUnits = map[byte]float64{'k': 1, 'm': 1 << 10, 'g': 1 << 20, 't': 1 << 30}
+
+// parseRSS parses an rss column, which is in KiB unless suffixed, into bytes.
+func parseRSS(value string) (int64, error) {
+	if kib, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return kib * 1024, nil
+	}
+	if value == "" {
+		return 0, fmt.Errorf("unexpected rss %q", value)
+	}
+	unit, ok := rssUnits[value[len(value)-1]]
+	if !ok {
+		return 0, fmt.Errorf("unexpected rss %q", value)
+	}
+	n, err := strconv.ParseFloat(value[:len(value)-1], 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("unexpected rss %q", value)
+	}
+	return int64(n * unit * 1024), nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParsePS(t *testing.T) {
+	now := time.Date(2024, 10, 14, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		file   string
+		hasCPU bool
+		want   []Process
+	}{
+		{"procps.txt", true, []Process{
+			{1, "root", "systemd", "/sbin/init splash", 0, 12876 * 1024, now.Add(-240 * time.Hour)},
+			{512, "root", "sshd", "sshd: /usr/sbin/sshd -D [listener] 0 of 10-100 startups", 0, 7680 * 1024, now.Add(-863990 * time.Second)},
+			{733, "systemd-timesync", "Web Content", "/usr/lib/firefox/firefox -contentproc -childID 1", 12.5, 1843200 * 1024, now.Add(-3725 * time.Second)},
+		}},
+		// BusyBox truncates user names and scales rss past 9999 KiB.
+		{"busybox.txt", false, []Process{
+			{1, "root", "init", "/sbin/init", 0, 1016 * 1024, now.Add(-24 * time.Hour)},
+			{312, "root", "sshd", "sshd: /usr/sbin/sshd [listener] 0 of 10-100 startups", 0, 2340 * 1024, now.Add(-605 * time.Second)},
+			{450, "systemd-", "java", "java -jar /srv/app.jar", 0, 1288490188, now.Add(-7530 * time.Second)},
+			{451, "root", "dockerd", "/usr/bin/dockerd", 0, 12 << 20, now.Add(-42 * time.Second)},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			output, err := os.ReadFile(filepath.Join("testdata", "ps", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := parsePS("ps", output, tt.hasCPU, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d processes, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("process %d:\ngot  %+v\nwant %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRunPSBusyBox(t *testing.T) {
+	runner := fakeRunner{psVariants[1].cmd: "ps/busybox.txt"}
+	got, err := runPS(context.Background(), runner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 || got[3].Command != "dockerd" || got[3].RSS != 12<<20 {
+		t.Errorf("got %+v, want the 4 BusyBox processes", got)
+	}
+}
+
+func TestParseRSS(t *testing.T) {
+	for value, want := range map[string]int64{"0": 0, "9999": 9999 << 10, "12m": 12 << 20, "1.5g": 3 << 29, "2t": 2 << 40} {
+		if got, err := parseRSS(value); err != nil || got != want {
+			t.Errorf("parseRSS(%q) = %d, %v, want %d", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "m", "12x", "-1m"} {
+		if _, err := parseRSS(value); err == nil {
+			t.Errorf("parseRSS(%q) succeeded, want an error", value)
+		}
+	}
+}
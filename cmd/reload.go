@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"slices"
+	"time"
+)
+
+type ConfigErrorEvent struct {
+	Time  time.Time `json:"time"`
+	File  string    `json:"file"`
+	Error string    `json:"error"`
+}
+
+// configWatcher polls the config file, since the container has no inotify
+// tooling and a cheap stat every few seconds is enough for a config file.
+type configWatcher struct {
+	path    string
+	modTime time.Time
+	size    int64
+	ticker  *time.Ticker
+}
+
+func newConfigWatcher(config Config) *configWatcher {
+	w := &configWatcher{path: config.Path}
+	w.changed()
+	w.reset(config.Schedule.Watch)
+	return w
+}
+
+func (w *configWatcher) reset(interval time.Duration) {
+	if w.ticker != nil {
+		w.ticker.Stop()
+		w.ticker = nil
+	}
+	if interval > 0 {
+		w.ticker = time.NewTicker(interval)
+	}
+}
+
+// C returns the tick channel, or nil (blocking forever) when watching is off.
+func (w *configWatcher) C() <-chan time.Time {
+	if w.ticker == nil {
+		return nil
+	}
+	return w.ticker.C
+}
+
+func (w *configWatcher) changed() bool {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false
+	}
+	if info.ModTime().Equal(w.modTime) && info.Size() == w.size {
+		return false
+	}
+	w.modTime = info.ModTime()
+	w.size = info.Size()
+	return true
+}
+
+func (w *configWatcher) Stop() {
+	w.reset(0)
+}
+
+// ReloadConfig loads the config file again. On failure the current config
+// is kept and the error is published to its exporters.
+func ReloadConfig(ctx context.Context, current Config) (Config, bool) {
+	log.Printf("Reloading config from %s", current.Path)
+	next, err := LoadConfig(current.Path)
+	if err != nil {
+		log.Printf("Warning: Failed to reload config, keeping the old one: %v", err)
+		publishConfigError(ctx, current, err)
+		return current, false
+	}
+
+	changes := diffConfigs(&current, &next)
+	if len(changes) == 0 {
+		log.Println("Config reloaded, nothing changed")
+	}
+	for _, change := range changes {
+		log.Printf("Config reloaded: %s", change)
+	}
+	return next, true
+}
+
+// withoutRawChecks drops the yaml nodes of the check options, which carry
+// line numbers, so that only the decoded options are compared.
+func withoutRawChecks(node MonitoringConfig) MonitoringConfig {
+	checks := make(map[string]CheckSpec, len(node.Checks))
+	for name, spec := range node.Checks {
+		spec.Raw = nil
+		checks[name] = spec
+	}
+	node.Checks = checks
+	return node
+}
+
+func diffConfigs(old *Config, next *Config) []string {
+	var changes []string
+
+	oldNodes := make(map[string]MonitoringConfig)
+	for _, node := range old.Nodes {
+		oldNodes[node.NodeName] = node
+	}
+	for _, node := range next.Nodes {
+		prev, ok := oldNodes[node.NodeName]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("node %s added", node.NodeName))
+		} else if !reflect.DeepEqual(withoutRawChecks(prev), withoutRawChecks(node)) {
+			changes = append(changes, fmt.Sprintf("node %s changed", node.NodeName))
+		}
+		delete(oldNodes, node.NodeName)
+	}
+	for _, node := range old.Nodes {
+		if _, ok := oldNodes[node.NodeName]; ok {
+			changes = append(changes, fmt.Sprintf("node %s removed", node.NodeName))
+		}
+	}
+
+	changes = append(changes, diffEndpoints("ICMP", old.Connectivity.ICMP, next.Connectivity.ICMP)...)
+	changes = append(changes, diffEndpoints("TCP", old.Connectivity.TCP, next.Connectivity.TCP)...)
+	changes = append(changes, diffEndpoints("HTTP", old.Connectivity.HTTP, next.Connectivity.HTTP)...)
+
+	oldExporters := make(map[string]MqttConnection)
+	for _, m := range old.Export.MQTT {
+		oldExporters[m.Name] = m
+	}
+	for _, m := range next.Export.MQTT {
+		prev, ok := oldExporters[m.Name]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("MQTT exporter %s added", m.Name))
+		} else if prev.Broker != m.Broker || prev.Topic != m.Topic || prev.ClientID != m.ClientID ||
+			prev.Qos != m.Qos || prev.Retain != m.Retain {
+			changes = append(changes, fmt.Sprintf("MQTT exporter %s changed", m.Name))
+		}
+		delete(oldExporters, m.Name)
+	}
+	for _, m := range old.Export.MQTT {
+		if _, ok := oldExporters[m.Name]; ok {
+			changes = append(changes, fmt.Sprintf("MQTT exporter %s removed", m.Name))
+		}
+	}
+
+	if old.Schedule.Interval != next.Schedule.Interval || old.Schedule.Splitter != next.Schedule.Splitter ||
+		old.Schedule.Watch != next.Schedule.Watch || old.Schedule.GracePeriod != next.Schedule.GracePeriod {
+		changes = append(changes, fmt.Sprintf("schedule changed: interval %s, splitter %s, watch %s, grace period %s",
+			next.Schedule.Interval, next.Schedule.Splitter, next.Schedule.Watch, next.Schedule.GracePeriod))
+	}
+	return changes
+}
+
+func diffEndpoints[T comparable](kind string, old []T, next []T) []string {
+	var changes []string
+	added, removed := 0, 0
+	for _, e := range next {
+		if !slices.Contains(old, e) {
+			added++
+		}
+	}
+	for _, e := range old {
+		if !slices.Contains(next, e) {
+			removed++
+		}
+	}
+	if added > 0 || removed > 0 {
+		changes = append(changes, fmt.Sprintf("%s endpoints: %d added, %d removed", kind, added, removed))
+	}
+	return changes
+}
+
+// publishConfigError uses its own connections, so that a run in progress
+// keeps its clients untouched.
+func publishConfigError(ctx context.Context, config Config, reloadErr error) {
+	event := ConfigErrorEvent{
+		Time:  time.Now(),
+		File:  config.Path,
+		Error: reloadErr.Error(),
+	}
+	payload, err := json.MarshalIndent(event, "", "  ")
+	if err != nil {
+		log.Printf("Warning: Failed to serialize config error: %v", err)
+		return
+	}
+
+	exporters := slices.Clone(config.Export.MQTT)
+	for i := range exporters {
+		exporters[i].ClientID += "-reload"
+		exporters[i].Client = nil
+	}
+	if err := InitializeMQTTConnections(ctx, exporters); err != nil {
+		log.Printf("Warning: Failed to initialize MQTT connections: %v", err)
+		return
+	}
+	defer CleanupMQTTConnections(exporters)
+	for _, m := range exporters {
+		if err := m.SendEvent(ctx, "config_error", string(payload), m.Retain); err != nil {
+			log.Printf("Warning: Failed to publish config error to MQTT %s: %v", m.Name, err)
+		}
+	}
+}
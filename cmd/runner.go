@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// stderrExcerptLen bounds the stderr kept in a CommandError.
+const stderrExcerptLen = 512
+
+// CommandError describes a remote command that did not succeed.
+type CommandError struct {
+	Command  string
+	ExitCode int
+	Stderr   string
+	Err      error
+}
+
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("command %q failed: %v", e.Command, e.Err)
+	if e.Stderr != "" {
+		msg += ": " + e.Stderr
+	}
+	return msg
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+func stderrExcerpt(stderr []byte) string {
+	excerpt := strings.TrimSpace(string(stderr))
+	if len(excerpt) > stderrExcerptLen {
+		excerpt = excerpt[:stderrExcerptLen] + "..."
+	}
+	return excerpt
+}
+
+// runCommand runs cmd in its own session and returns its stdout. If ctx is
+// cancelled the session is closed, so a hung remote command cannot block the caller.
+func runCommand(ctx context.Context, client *ssh.Client, cmd string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &CommandError{Command: cmd, Err: fmt.Errorf("not started: %w", err)}
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, &CommandError{Command: cmd, Err: fmt.Errorf("failed to create session: %v", err)}
+	}
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Run(cmd)
+	}()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		session.Signal(ssh.SIGKILL)
+		session.Close()
+		<-done
+		return stdout.Bytes(), &CommandError{Command: cmd, Stderr: stderrExcerpt(stderr.Bytes()), Err: fmt.Errorf("cancelled: %w", ctx.Err())}
+	}
+	if err != nil {
+		cmdErr := &CommandError{Command: cmd, Stderr: stderrExcerpt(stderr.Bytes()), Err: err}
+		var exitErr *ssh.ExitError
+		if errors.As(err, &exitErr) {
+			cmdErr.ExitCode = exitErr.ExitStatus()
+		}
+		return stdout.Bytes(), cmdErr
+	}
+	return stdout.Bytes(), nil
+}
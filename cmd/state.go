@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// stateMtx guards state files, since nodes are checked concurrently and a
+// reload may start a run while the previous one still saves.
+var stateMtx sync.Mutex
+
+// StateStore keeps what checks remember between runs, e.g. the last boot
+// time, in a JSON file keyed by node and check. A nil store remembers nothing.
+type StateStore struct {
+	file string
+}
+
+func NewStateStore(file string) *StateStore {
+	return &StateStore{file: file}
+}
+
+// read returns the whole state, a missing file is an empty state.
+func (s *StateStore) read() (map[string]map[string]json.RawMessage, error) {
+	nodes := make(map[string]map[string]json.RawMessage)
+	data, err := os.ReadFile(s.file)
+	if os.IsNotExist(err) {
+		return nodes, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read state %s: %v", s.file, err)
+	}
+	if err := json.Unmarshal(data, &nodes); err != nil {
+		return nil, fmt.Errorf("unable to parse state %s: %v", s.file, err)
+	}
+	return nodes, nil
+}
+
+// Load decodes the state saved by check for node into v and reports
+// whether there was any.
+func (s *StateStore) Load(node string, check string, v any) (bool, error) {
+	if s == nil {
+		return false, nil
+	}
+	stateMtx.Lock()
+	defer stateMtx.Unlock()
+	nodes, err := s.read()
+	if err != nil {
+		return false, err
+	}
+	data, ok := nodes[node][check]
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("unable to parse state of %s for %s: %v", check, node, err)
+	}
+	return true, nil
+}
+
+// Save replaces the state of check for node and writes the file.
+func (s *StateStore) Save(node string, check string, v any) error {
+	if s == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("unable to encode state of %s for %s: %v", check, node, err)
+	}
+	stateMtx.Lock()
+	defer stateMtx.Unlock()
+	nodes, err := s.read()
+	if err != nil {
+		return err
+	}
+	if nodes[node] == nil {
+		nodes[node] = make(map[string]json.RawMessage)
+	}
+	nodes[node][check] = data
+	return s.write(nodes)
+}
+
+// write replaces the file in one rename, so a crash never leaves it half written.
+func (s *StateStore) write(nodes map[string]map[string]json.RawMessage) error {
+	data, err := json.MarshalIndent(nodes, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode state: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.file), 0o700); err != nil {
+		return fmt.Errorf("unable to create state directory: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.file), filepath.Base(s.file)+".*")
+	if err != nil {
+		return fmt.Errorf("unable to write state %s: %v", s.file, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to write state %s: %v", s.file, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to write state %s: %v", s.file, err)
+	}
+	if err := os.Rename(tmp.Name(), s.file); err != nil {
+		return fmt.Errorf("unable to write state %s: %v", s.file, err)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const CheckSystem = "system"
+
+func init() {
+	RegisterChecker(systemCheck{}, true)
+}
+
+// bootTimeJitter is how far the boot time may move between runs without a
+// reboot, since the kernel derives it from the clock, which NTP adjusts.
+const bootTimeJitter = time.Minute
+
+type systemOptions struct {
+	// History is how many reboots and shutdowns from `last -x` to list, 0 skips it.
+	History int `yaml:"history" default:"10"`
+}
+
+func (o *systemOptions) Validate() error {
+	if o.History < 0 {
+		return fmt.Errorf("history must not be negative, got %d", o.History)
+	}
+	return nil
+}
+
+type SystemInfo struct {
+	Kernel   string    `json:"kernel"`
+	Uptime   float64   `json:"uptime"`
+	BootTime time.Time `json:"boot_time"`
+	// Rebooted is set when the boot time differs from the one seen on the
+	// previous run, e.g. after the provider restarted the VPS.
+	Rebooted         bool          `json:"rebooted"`
+	PreviousBootTime *time.Time    `json:"previous_boot_time,omitempty"`
+	Events           []SystemEvent `json:"events,omitempty"`
+}
+
+// SystemEvent is a reboot or shutdown recorded in wtmp. For reboots, End is
+// when the system went down again, unset while it is still running.
+type SystemEvent struct {
+	Type   string     `json:"type"`
+	Kernel string     `json:"kernel"`
+	Time   time.Time  `json:"time"`
+	End    *time.Time `json:"end,omitempty"`
+}
+
+func (s *SystemInfo) String() string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("\tKernel: %s, Boot Time: %s, Uptime: %s, Rebooted: %t\n",
+		s.Kernel, s.BootTime.Format(time.RFC3339), time.Duration(s.Uptime)*time.Second, s.Rebooted))
+	for _, event := range s.Events {
+		sb.WriteString(fmt.Sprintf("\t%s: %s (%s)", event.Type, event.Time.Format(time.RFC3339), event.Kernel))
+		if event.End != nil {
+			sb.WriteString(fmt.Sprintf(" - %s", event.End.Format(time.RFC3339)))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+type systemState struct {
+	BootTime time.Time `json:"boot_time"`
+}
+
+type systemCheck struct{}
+
+func (systemCheck) Name() string { return CheckSystem }
+func (systemCheck) Options() any { return &systemOptions{} }
+
+func (systemCheck) Run(ctx context.Context, env *CheckEnv, opts any) (any, error) {
+	o := opts.(*systemOptions)
+	cmd := "cat /proc/uptime; grep '^btime ' /proc/stat; uname -r"
+	output, err := env.Runner.Run(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute command: %w", err)
+	}
+	info, err := parseSystem(cmd, output)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeName := env.Node.NodeName
+	var previous systemState
+	if found, err := env.State.Load(nodeName, CheckSystem, &previous); err != nil {
+		log.Printf("[%s] Unable to load previous boot time: %v", nodeName, err)
+	} else if found {
+		info.PreviousBootTime = &previous.BootTime
+		if diff := info.BootTime.Sub(previous.BootTime).Abs(); diff > bootTimeJitter {
+			log.Printf("[%s] Rebooted: boot time changed from %s to %s", nodeName,
+				previous.BootTime.Format(time.RFC3339), info.BootTime.Format(time.RFC3339))
+			info.Rebooted = true
+		} else {
+			// Keep the first boot time seen, so jitter cannot add up.
+			info.BootTime = previous.BootTime
+		}
+	}
+
+	if o.History == 0 {
+		return info, nil
+	}
+	// wtmp history is optional, e.g. BusyBox last has no -x.
+	lastCmd := "LC_ALL=C TZ=UTC0 last -w -x -F reboot shutdown"
+	output, err = env.Runner.Run(ctx, lastCmd)
+	if err != nil {
+		log.Printf("[%s] Skipping reboot history: %v", nodeName, err)
+		return info, nil
+	}
+	info.Events = parseSystemEvents(output)
+	if len(info.Events) > o.History {
+		info.Events = info.Events[:o.History]
+	}
+	return info, nil
+}
+
+// saveBootTime records the boot time of result, so the next run can tell
+// whether the node rebooted. It is called only once result was published,
+// otherwise a reboot would never be reported.
+func (c *MonitoringConfig) saveBootTime(result *MonitoringResult) {
+	info, ok := result.Checks[CheckSystem].Result.(*SystemInfo)
+	if !ok {
+		return
+	}
+	if err := c.State.Save(c.NodeName, CheckSystem, systemState{BootTime: info.BootTime}); err != nil {
+		log.Printf("[%s] Unable to save boot time: %v", c.NodeName, err)
+	}
+}
+
+func parseSystem(cmd string, output []byte) (*SystemInfo, error) {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) != 3 {
+		return nil, parseErrorf(cmd, "expected 3 lines, got %d: %s", len(lines), string(output))
+	}
+	fields := strings.Fields(lines[0])
+	if len(fields) == 0 {
+		return nil, parseErrorf(cmd, "unexpected /proc/uptime: %s", lines[0])
+	}
+	uptime, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return nil, parseErrorf(cmd, "unexpected /proc/uptime: %s", lines[0])
+	}
+	fields = strings.Fields(lines[1])
+	if len(fields) != 2 {
+		return nil, parseErrorf(cmd, "unexpected btime line: %s", lines[1])
+	}
+	btime, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, parseErrorf(cmd, "unexpected btime line: %s", lines[1])
+	}
+	return &SystemInfo{
+		Kernel:   strings.TrimSpace(lines[2]),
+		Uptime:   uptime,
+		BootTime: time.Unix(btime, 0).UTC(),
+	}, nil
+}
+
+// lastTimeLayout is the time format of `last -F`, taking five fields.
+const lastTimeLayout = "Mon Jan 2 15:04:05 2006"
+
+// parseSystemEvents parses `last -x -F` lines such as
+//
+//	reboot   system boot  6.8.0-45-generic Mon Oct 14 10:00:01 2024   still running
+//	shutdown system down  6.8.0-45-generic Mon Oct 14 09:59:50 2024 - Mon Oct 14 10:00:01 2024  (00:00)
+//
+// and skips anything else, like runlevel changes.
+func parseSystemEvents(output []byte) []SystemEvent {
+	var events []SystemEvent
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 9 || fields[1] != "system" || (fields[0] != "reboot" && fields[0] != "shutdown") {
+			continue
+		}
+		start, err := time.Parse(lastTimeLayout, strings.Join(fields[4:9], " "))
+		if err != nil {
+			continue
+		}
+		event := SystemEvent{Type: fields[0], Kernel: fields[3], Time: start}
+		if len(fields) >= 15 && fields[9] == "-" {
+			if end, err := time.Parse(lastTimeLayout, strings.Join(fields[10:15], " ")); err == nil {
+				event.End = &end
+			}
+		}
+		events = append(events, event)
+	}
+	return events
+}
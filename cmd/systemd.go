@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const CheckSystemd = "systemd"
+
+func init() {
+	RegisterChecker(systemdCheck{}, false)
+}
+
+// unitNamePattern keeps unit names safe to pass to the shell in single
+// quotes, which leave the backslashes of escaped names like
+// dev-disk-by\x2dlabel.mount alone.
+var unitNamePattern = regexp.MustCompile(`^[A-Za-z0-9@._:\\-]+$`)
+
+type systemdOptions struct {
+	// Units are watched even while they are not failed, e.g. to see restarts.
+	Units []string `yaml:"units"`
+}
+
+func (o *systemdOptions) Validate() error {
+	for _, unit := range o.Units {
+		if !unitNamePattern.MatchString(unit) {
+			return fmt.Errorf("invalid unit name %q", unit)
+		}
+	}
+	return nil
+}
+
+// SystemdInfo lists failed units and the state of watched units, keyed by
+// the name used in config. Status is critical when any unit failed or a
+// watched unit is not active.
+type SystemdInfo struct {
+	Failed []SystemdUnit          `json:"failed"`
+	Units  map[string]SystemdUnit `json:"units,omitempty"`
+	Status string                 `json:"status"`
+}
+
+type SystemdUnit struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	LoadState   string `json:"load_state"`
+	ActiveState string `json:"active_state"`
+	SubState    string `json:"sub_state"`
+	// Restarts counts automatic restarts by Restart=, only services have it.
+	Restarts int        `json:"restarts"`
+	Since    *time.Time `json:"since,omitempty"`
+}
+
+func (u SystemdUnit) active() bool {
+	return u.ActiveState == "active" || u.ActiveState == "reloading"
+}
+
+func (u SystemdUnit) String() string {
+	s := fmt.Sprintf("%s: %s/%s/%s, Restarts: %d", u.Name, u.LoadState, u.ActiveState, u.SubState, u.Restarts)
+	if u.Since != nil {
+		s += fmt.Sprintf(", Since: %s", u.Since.Format(time.RFC3339))
+	}
+	return s
+}
+
+func (s *SystemdInfo) String() string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("\tStatus: %s, Failed Units: %d\n", s.Status, len(s.Failed)))
+	for _, unit := range s.Failed {
+		sb.WriteString(fmt.Sprintf("\tFailed: %s\n", unit))
+	}
+	names := make([]string, 0, len(s.Units))
+	for name := range s.Units {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		sb.WriteString(fmt.Sprintf("\t%s\n", s.Units[name]))
+	}
+	return sb.String()
+}
+
+type systemdCheck struct{}
+
+func (systemdCheck) Name() string { return CheckSystemd }
+func (systemdCheck) Options() any { return &systemdOptions{} }
+
+func (systemdCheck) Run(ctx context.Context, env *CheckEnv, opts any) (any, error) {
+	o := opts.(*systemdOptions)
+	cmd := "systemctl list-units --failed --plain --no-legend --no-pager"
+	output, err := env.Runner.Run(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute command: %w", err)
+	}
+	info := &SystemdInfo{Failed: parseFailedUnits(output), Status: StatusOK}
+	if len(info.Failed) > 0 {
+		info.Status = StatusCritical
+	}
+	if len(o.Units) == 0 {
+		return info, nil
+	}
+
+	// Timestamps are printed in the local zone of the node, UTC parses reliably.
+	showCmd := "LC_ALL=C TZ=UTC0 systemctl show --no-pager " +
+		"--property=Id,Description,LoadState,ActiveState,SubState,NRestarts,StateChangeTimestamp -- '" +
+		strings.Join(o.Units, "' '") + "'"
+	output, err = env.Runner.Run(ctx, showCmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute command: %w", err)
+	}
+	units, err := parseUnitProperties(showCmd, output)
+	if err != nil {
+		return nil, err
+	}
+	if len(units) != len(o.Units) {
+		return nil, parseErrorf(showCmd, "expected %d units, got %d", len(o.Units), len(units))
+	}
+	info.Units = make(map[string]SystemdUnit, len(units))
+	for i, unit := range units {
+		info.Units[o.Units[i]] = unit
+		if !unit.active() {
+			info.Status = StatusCritical
+		}
+	}
+	return info, nil
+}
+
+// parseFailedUnits parses `systemctl list-units --plain --no-legend` lines
+// of unit, load, active and sub state and description. Some versions still
+// mark failed units with a leading dot.
+func parseFailedUnits(output []byte) []SystemdUnit {
+	units := []SystemdUnit{}
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && (fields[0] == "●" || fields[0] == "*") {
+			fields = fields[1:]
+		}
+		if len(fields) < 4 {
+			continue
+		}
+		units = append(units, SystemdUnit{
+			Name:        fields[0],
+			LoadState:   fields[1],
+			ActiveState: fields[2],
+			SubState:    fields[3],
+			Description: strings.Join(fields[4:], " "),
+		})
+	}
+	return units
+}
+
+// parseUnitProperties parses `systemctl show` output, one block of
+// Key=Value lines per unit, in the order the units were given.
+func parseUnitProperties(cmd string, output []byte) ([]SystemdUnit, error) {
+	var units []SystemdUnit
+	var unit *SystemdUnit
+	for _, line := range strings.Split(string(output), "\n") {
+		key, value, found := strings.Cut(strings.TrimSpace(line), "=")
+		if !found {
+			unit = nil
+			continue
+		}
+		if unit == nil {
+			units = append(units, SystemdUnit{})
+			unit = &units[len(units)-1]
+		}
+		switch key {
+		case "Id":
+			unit.Name = value
+		case "Description":
+			unit.Description = value
+		case "LoadState":
+			unit.LoadState = value
+		case "ActiveState":
+			unit.ActiveState = value
+		case "SubState":
+			unit.SubState = value
+		case "NRestarts":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, parseErrorf(cmd, "unexpected NRestarts: %s", value)
+			}
+			unit.Restarts = n
+		case "StateChangeTimestamp":
+			// Empty for units that never changed state, e.g. not found ones.
+			if value == "" || value == "n/a" {
+				continue
+			}
+			since, err := time.Parse("Mon 2006-01-02 15:04:05 MST", value)
+			if err != nil {
+				return nil, parseErrorf(cmd, "unexpected StateChangeTimestamp: %s", value)
+			}
+			unit.Since = &since
+		}
+	}
+	return units, nil
+}
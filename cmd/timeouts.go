@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Built-in timeouts, used when neither the global nor the node config sets one.
+var (
+	defaultCheckTimeout = 60 * time.Second
+	defaultNodeTimeout  = 10 * time.Minute
+	defaultTimeouts     = map[string]time.Duration{
+		CheckConnectivity: 5 * time.Minute,
+	}
+)
+
+type TimeoutConfig struct {
+	DefaultRaw string                   `yaml:"default"`
+	NodeRaw    string                   `yaml:"node"`
+	ChecksRaw  map[string]string        `yaml:"checks"`
+	Default    time.Duration            `yaml:"-"`
+	Node       time.Duration            `yaml:"-"`
+	Checks     map[string]time.Duration `yaml:"-"`
+}
+
+// Merge returns t with every value it does not set taken from parent.
+func (t TimeoutConfig) Merge(parent TimeoutConfig) TimeoutConfig {
+	if t.Default == 0 {
+		t.Default = parent.Default
+	}
+	if t.Node == 0 {
+		t.Node = parent.Node
+	}
+	checks := make(map[string]time.Duration)
+	for name, d := range parent.Checks {
+		checks[name] = d
+	}
+	for name, d := range t.Checks {
+		checks[name] = d
+	}
+	t.Checks = checks
+	return t
+}
+
+func (t *TimeoutConfig) Check(name string) time.Duration {
+	if d, ok := t.Checks[name]; ok && d > 0 {
+		return d
+	}
+	if d, ok := defaultTimeouts[name]; ok && t.Default == 0 {
+		return d
+	}
+	if t.Default > 0 {
+		return t.Default
+	}
+	return defaultCheckTimeout
+}
+
+func (t *TimeoutConfig) NodeBudget() time.Duration {
+	if t.Node > 0 {
+		return t.Node
+	}
+	return defaultNodeTimeout
+}
+
+// CheckTimeoutError marks a check that was stopped because it ran out of time.
+type CheckTimeoutError struct {
+	Check   string
+	Timeout time.Duration
+	Node    bool
+	Err     error
+}
+
+func (e *CheckTimeoutError) Error() string {
+	if e.Node {
+		return fmt.Sprintf("timeout: node budget of %s exhausted before check %s finished", e.Timeout, e.Check)
+	}
+	return fmt.Sprintf("timeout: check %s did not finish within %s", e.Check, e.Timeout)
+}
+
+func (e *CheckTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// runCheck runs a single check with its own deadline. A timed out check has
+// its session closed by runCommand and the next check still gets a chance.
+func (c *MonitoringConfig) runCheck(ctx context.Context, name string, check func(ctx context.Context) error) *CheckError {
+	return newCheckError(c.checkWithTimeout(ctx, name, check), ErrorCategoryCommand)
+}
+
+func (c *MonitoringConfig) checkWithTimeout(ctx context.Context, name string, check func(ctx context.Context) error) error {
+	timeout := c.Timeouts.Check(name)
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := check(checkCtx)
+	if err == nil || !errors.Is(checkCtx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	if ctx.Err() == nil {
+		log.Printf("[%s] Check %s timed out after %s, session closed", c.NodeName, name, timeout)
+		return &CheckTimeoutError{Check: name, Timeout: timeout, Err: err}
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		log.Printf("[%s] Node budget of %s exhausted during check %s", c.NodeName, c.Timeouts.NodeBudget(), name)
+		return &CheckTimeoutError{Check: name, Timeout: c.Timeouts.NodeBudget(), Node: true, Err: err}
+	}
+	return err
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type ConfigProblem struct {
+	Line    int
+	Path    string
+	Message string
+}
+
+func (p ConfigProblem) String() string {
+	sb := strings.Builder{}
+	if p.Line > 0 {
+		sb.WriteString(fmt.Sprintf("line %d: ", p.Line))
+	}
+	if p.Path != "" {
+		sb.WriteString(p.Path)
+		sb.WriteString(": ")
+	}
+	sb.WriteString(p.Message)
+	return sb.String()
+}
+
+// ConfigError lists every problem found in a config file.
+type ConfigError struct {
+	File     string
+	Problems []ConfigProblem
+}
+
+// sortProblems orders problems by line, keeping ones without a line last.
+func (v *configValidator) sortProblems() {
+	slices.SortStableFunc(v.problems, func(a, b ConfigProblem) int {
+		switch {
+		case a.Line == b.Line:
+			return 0
+		case a.Line == 0:
+			return 1
+		case b.Line == 0:
+			return -1
+		}
+		return a.Line - b.Line
+	})
+}
+
+func (e *ConfigError) Error() string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("%s: %d problem(s) found", e.File, len(e.Problems)))
+	for _, p := range e.Problems {
+		sb.WriteString("\n  ")
+		sb.WriteString(p.String())
+	}
+	return sb.String()
+}
+
+// configValidator collects problems and resolves config paths to lines of
+// the parsed YAML document.
+type configValidator struct {
+	root     *yaml.Node
+	problems []ConfigProblem
+}
+
+var yamlErrorLine = regexp.MustCompile(`^line (\d+): (.*)$`)
+
+func (v *configValidator) addYAMLErrors(err *yaml.TypeError) {
+	for _, msg := range err.Errors {
+		problem := ConfigProblem{Message: msg}
+		if m := yamlErrorLine.FindStringSubmatch(msg); m != nil {
+			problem.Line, _ = strconv.Atoi(m[1])
+			problem.Message = m[2]
+		}
+		v.problems = append(v.problems, problem)
+	}
+}
+
+// find returns the YAML node at the given path of mapping keys and
+// sequence indices, or nil if it is not present in the document.
+func (v *configValidator) find(path ...any) *yaml.Node {
+	node := v.root
+	if node != nil && node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	for _, elem := range path {
+		if node == nil {
+			return nil
+		}
+		switch key := elem.(type) {
+		case string:
+			if node.Kind != yaml.MappingNode {
+				return nil
+			}
+			var next *yaml.Node
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == key {
+					next = node.Content[i+1]
+				}
+			}
+			node = next
+		case int:
+			if node.Kind != yaml.SequenceNode || key >= len(node.Content) {
+				return nil
+			}
+			node = node.Content[key]
+		}
+	}
+	return node
+}
+
+func formatPath(path []any) string {
+	sb := strings.Builder{}
+	for _, elem := range path {
+		switch key := elem.(type) {
+		case string:
+			if sb.Len() > 0 {
+				sb.WriteString(".")
+			}
+			sb.WriteString(key)
+		case int:
+			sb.WriteString(fmt.Sprintf("[%d]", key))
+		}
+	}
+	return sb.String()
+}
+
+// addf records a problem at the given path. When the exact key is missing
+// the line of the closest existing parent is used.
+func (v *configValidator) addf(path []any, format string, args ...any) {
+	problem := ConfigProblem{
+		Path:    formatPath(path),
+		Message: fmt.Sprintf(format, args...),
+	}
+	for i := len(path); i >= 0; i-- {
+		if node := v.find(path[:i]...); node != nil {
+			problem.Line = node.Line
+			break
+		}
+	}
+	v.problems = append(v.problems, problem)
+}
+
+// addLine records a problem at a known line, for values whose place in the
+// file is not described by their config path.
+func (v *configValidator) addLine(line int, path []any, format string, args ...any) {
+	v.problems = append(v.problems, ConfigProblem{
+		Line:    line,
+		Path:    formatPath(path),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *configValidator) has(path ...any) bool {
+	return v.find(path...) != nil
+}
+
+func at(elems ...any) []any {
+	return elems
+}
+
+// applyDefaults fills zero-valued fields that carry a `default` struct tag.
+func applyDefaults(value reflect.Value) {
+	switch value.Kind() {
+	case reflect.Pointer:
+		if !value.IsNil() {
+			applyDefaults(value.Elem())
+		}
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			applyDefaults(value.Index(i))
+		}
+	case reflect.Struct:
+		t := value.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := value.Field(i)
+			if !field.CanSet() {
+				continue
+			}
+			def, ok := t.Field(i).Tag.Lookup("default")
+			if !ok || !field.IsZero() {
+				applyDefaults(field)
+				continue
+			}
+			switch field.Kind() {
+			case reflect.String:
+				field.SetString(def)
+			case reflect.Int:
+				if n, err := strconv.Atoi(def); err == nil {
+					field.SetInt(int64(n))
+				}
+			case reflect.Int64:
+				if field.Type() == reflect.TypeOf(time.Duration(0)) {
+					if d, err := time.ParseDuration(def); err == nil {
+						field.SetInt(int64(d))
+					}
+				}
+			case reflect.Bool:
+				if b, err := strconv.ParseBool(def); err == nil {
+					field.SetBool(b)
+				}
+			case reflect.Float64:
+				if f, err := strconv.ParseFloat(def, 64); err == nil {
+					field.SetFloat(f)
+				}
+			}
+		}
+	}
+}
+
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
+}
+
+func (v *configValidator) validateHostKey(p []any, h *HostKeyConfig) {
+	switch h.Mode {
+	case "", HostKeyModeInsecure, HostKeyModeKnownHosts, HostKeyModeTOFU:
+	case HostKeyModeFingerprint:
+		if h.Fingerprint == "" {
+			v.addf(append(p, "fingerprint"), "required for host key mode %q", h.Mode)
+		} else if !strings.HasPrefix(h.Fingerprint, "SHA256:") && !strings.HasPrefix(h.Fingerprint, "MD5:") {
+			v.addf(append(p, "fingerprint"), "must start with SHA256: or MD5:")
+		}
+	default:
+		v.addf(append(p, "mode"), "unknown host key mode %q, expected one of insecure, known_hosts, fingerprint, tofu", h.Mode)
+	}
+}
+
+func (v *configValidator) validateAuth(p []any, idFile string, a *AuthConfig) {
+	if idFile == "" && a.KeyFile == "" && !a.Agent {
+		v.addf(append(p, "id_file"), "is required unless auth.key_file or auth.agent is set")
+	}
+	if a.PassphraseEnv != "" && a.PassphraseFile != "" {
+		v.addf(append(p, "auth"), "passphrase_env and passphrase_file are mutually exclusive")
+	}
+}
+
+// validateTimeouts parses the raw timeout values into t.
+func (v *configValidator) validateTimeouts(p []any, t *TimeoutConfig) {
+	if t.DefaultRaw != "" {
+		t.Default = v.validateDuration(append(p, "default"), t.DefaultRaw, false)
+	}
+	if t.NodeRaw != "" {
+		t.Node = v.validateDuration(append(p, "node"), t.NodeRaw, false)
+	}
+	t.Checks = make(map[string]time.Duration)
+	for name, raw := range t.ChecksRaw {
+		if lookupChecker(name) == nil {
+			v.addf(append(p, "checks", name), "unknown check %q, expected one of %s", name, strings.Join(checkerNames(), ", "))
+			continue
+		}
+		t.Checks[name] = v.validateDuration(append(p, "checks", name), raw, false)
+	}
+}
+
+func (v *configValidator) validateNode(p []any, node *MonitoringConfig) {
+	if node.NodeName == "" {
+		v.addf(append(p, "name"), "is required")
+	}
+	if node.IP == "" {
+		v.addf(append(p, "ip"), "is required")
+	}
+	if node.UserName == "" {
+		v.addf(append(p, "user"), "is required")
+	}
+	if (v.has(append(p, "port")...) || node.Port != 0) && !validPort(node.Port) {
+		v.addf(append(p, "port"), "must be between 1 and 65535, got %d", node.Port)
+	}
+	v.validateAuth(p, node.IDFile, &node.Auth)
+	v.validateHostKey(append(p, "host_key"), &node.HostKey)
+	v.validateTimeouts(append(p, "timeouts"), &node.Timeouts)
+	v.validateChecks(append(p, "checks"), node.Checks)
+	for j := range node.Jump {
+		jp := append(slices.Clone(p), "jump", j)
+		jump := &node.Jump[j]
+		if jump.IP == "" {
+			v.addf(append(jp, "ip"), "is required")
+		}
+		if jump.Port != 0 && !validPort(jump.Port) {
+			v.addf(append(jp, "port"), "must be between 1 and 65535, got %d", jump.Port)
+		}
+		v.validateHostKey(append(jp, "host_key"), &jump.HostKey)
+	}
+}
+
+func validateBroker(broker string) error {
+	if broker == "" {
+		return fmt.Errorf("is required")
+	}
+	u, err := url.Parse(broker)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %v", err)
+	}
+	switch u.Scheme {
+	case "tcp", "mqtt", "ssl", "tls", "mqtts", "ws", "wss", "unix":
+	default:
+		return fmt.Errorf("unsupported scheme %q in %q, expected tcp://, ssl://, ws:// or similar", u.Scheme, broker)
+	}
+	if u.Scheme != "unix" && u.Host == "" {
+		return fmt.Errorf("missing host in %q", broker)
+	}
+	return nil
+}
+
+func (v *configValidator) validateDuration(p []any, raw string, allowZero bool) time.Duration {
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		v.addf(p, "invalid duration %q", raw)
+		return 0
+	}
+	if d < 0 || (d == 0 && !allowZero) {
+		v.addf(p, "must be positive, got %s", raw)
+	}
+	return d
+}
+
+// validateExplicit checks values as written in the file, before defaults
+// and inventories are applied, so the reported lines are exact.
+func (v *configValidator) validateExplicit(config *Config) {
+	seen := make(map[string]int)
+	for i := range config.Nodes {
+		node := &config.Nodes[i]
+		p := at("nodes", i)
+		v.validateNode(p, node)
+		v.validateNodeGroups(p, node, config.Groups)
+		if first, ok := seen[node.NodeName]; ok && node.NodeName != "" {
+			v.addf(append(p, "name"), "duplicate node name %q, first defined at nodes[%d]", node.NodeName, first)
+		} else {
+			seen[node.NodeName] = i
+		}
+	}
+
+	for i, e := range config.Connectivity.ICMP {
+		if e.Name == "" || e.Address == "" {
+			v.addf(at("connectivity", "icmp", i), "name and address are required")
+		}
+	}
+	for i, e := range config.Connectivity.TCP {
+		if e.Name == "" || e.Address == "" {
+			v.addf(at("connectivity", "tcp", i), "name and address are required")
+		}
+		if !validPort(e.Port) {
+			v.addf(at("connectivity", "tcp", i, "port"), "must be between 1 and 65535, got %d", e.Port)
+		}
+	}
+	for i, e := range config.Connectivity.HTTP {
+		if e.Name == "" || e.Address == "" {
+			v.addf(at("connectivity", "http", i), "name and address are required")
+		} else if u, err := url.Parse(e.Address); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			v.addf(at("connectivity", "http", i, "address"), "must be an http:// or https:// URL, got %q", e.Address)
+		}
+	}
+
+	names := make(map[string]bool)
+	for i := range config.Export.MQTT {
+		m := &config.Export.MQTT[i]
+		p := at("export", "mqtt", i)
+		if m.Name == "" {
+			v.addf(append(p, "name"), "is required")
+		} else if names[m.Name] {
+			v.addf(append(p, "name"), "duplicate exporter name %q", m.Name)
+		}
+		names[m.Name] = true
+		if err := validateBroker(m.Broker); err != nil {
+			v.addf(append(p, "broker"), "%v", err)
+		}
+		if m.Topic == "" {
+			v.addf(append(p, "topic"), "is required")
+		} else if strings.ContainsAny(m.Topic, "+#") {
+			v.addf(append(p, "topic"), "must not contain wildcards, got %q", m.Topic)
+		}
+		if m.Qos < 0 || m.Qos > 2 {
+			v.addf(append(p, "qos"), "must be 0, 1 or 2, got %d", m.Qos)
+		}
+	}
+
+	v.validateGroups(config)
+
+	if v.has("schedule", "interval") && config.Schedule.IntervalRaw == "" {
+		v.addf(at("schedule", "interval"), "must not be empty")
+	}
+}
+
+// validateMerged checks the final node list, including imported ones.
+func (v *configValidator) validateMerged(config *Config, explicit int) {
+	config.Schedule.Interval = v.validateDuration(at("schedule", "interval"), config.Schedule.IntervalRaw, false)
+	config.Schedule.Splitter = v.validateDuration(at("schedule", "splitter"), config.Schedule.SplitterRaw, true)
+	config.Schedule.GracePeriod = v.validateDuration(at("schedule", "grace_period"), config.Schedule.GraceRaw, true)
+	if config.Schedule.WatchRaw != "" {
+		config.Schedule.Watch = v.validateDuration(at("schedule", "watch"), config.Schedule.WatchRaw, true)
+	}
+	v.validateTimeouts(at("timeouts"), &config.Timeouts)
+
+	if len(config.Nodes) == 0 {
+		v.addf(at("nodes"), "at least one node is required, either in nodes or from inventory")
+	}
+
+	for i := explicit; i < len(config.Nodes); i++ {
+		node := &config.Nodes[i]
+		before := len(v.problems)
+		v.validateNode(nil, node)
+		v.validateNodeGroups(nil, node, config.Groups)
+		for j := before; j < len(v.problems); j++ {
+			v.problems[j].Line = 0
+			if inventory := v.find("inventory"); inventory != nil {
+				v.problems[j].Line = inventory.Line
+			}
+			v.problems[j].Path = fmt.Sprintf("inventory node %q: %s", node.NodeName, strings.TrimPrefix(v.problems[j].Path, "."))
+		}
+	}
+}
@@ -13,6 +13,7 @@ IMAGE_NAME="lookout-mosquitto:latest"
 SSH_DIR="/opt/ssh_keys"
 MOSQUITTO_DATA="/opt/mosquitto_data"
 MOSQUITTO_CONFIG="/opt/mosquitto_config"
+LOOKOUT_STATE="/opt/lookout_state"
 CONFIG_YAML="config.yaml"
 
 
@@ -49,7 +50,7 @@ fi
 
 # Create directories
 echo "Creating directories..."
-mkdir -p "$SSH_DIR" "$MOSQUITTO_DATA" "$MOSQUITTO_CONFIG"
+mkdir -p "$SSH_DIR" "$MOSQUITTO_DATA" "$MOSQUITTO_CONFIG" "$LOOKOUT_STATE"
 chmod 700 "$SSH_DIR"
 chmod 755 "$MOSQUITTO_DATA" "$MOSQUITTO_CONFIG"
 
@@ -159,6 +159,52 @@ def write_hass_yaml(config, output_file):
       value_template: "{{{{ (value_json.total_space | float / 1024 / 1024 / 1024) | round(1) }}}}"
       unit_of_measurement: "GB"
 
+    - name: "Lookout: {node_name} Memory Usage"
+      state_topic: "vps-monitoring/{node_name}"
+      value_template: >
+        {{% set mem = (value_json.checks | default({{}})).memory | default({{}}) %}}
+        {{{{ mem.result.usage | round(1) if mem.result is defined else none }}}}
+      unit_of_measurement: "%"
+      icon: mdi:memory
+
+    - name: "Lookout: {node_name} Swap Usage"
+      state_topic: "vps-monitoring/{node_name}"
+      value_template: >
+        {{% set mem = (value_json.checks | default({{}})).memory | default({{}}) %}}
+        {{{{ mem.result.swap_usage | round(1) if mem.result is defined else none }}}}
+      unit_of_measurement: "%"
+      icon: mdi:swap-horizontal
+
+    - name: "Lookout: {node_name} CPU Usage"
+      state_topic: "vps-monitoring/{node_name}"
+      value_template: >
+        {{% set cpu = (value_json.checks | default({{}})).cpu | default({{}}) %}}
+        {{{{ cpu.result.usage | round(1) if cpu.result is defined else none }}}}
+      unit_of_measurement: "%"
+      icon: mdi:cpu-64-bit
+
+    - name: "Lookout: {node_name} Load per Core"
+      state_topic: "vps-monitoring/{node_name}"
+      value_template: >
+        {{% set cpu = (value_json.checks | default({{}})).cpu | default({{}}) %}}
+        {{{{ cpu.result.load5_per_core | round(2) if cpu.result is defined else none }}}}
+      icon: mdi:gauge
+
+    - name: "Lookout: {node_name} Uptime"
+      state_topic: "vps-monitoring/{node_name}"
+      value_template: >
+        {{% set sys = (value_json.checks | default({{}})).system | default({{}}) %}}
+        {{{{ (sys.result.uptime / 86400) | round(1) if sys.result is defined else none }}}}
+      unit_of_measurement: "d"
+      icon: mdi:timer-outline
+
+    - name: "Lookout: {node_name} Failed SSH Logins"
+      state_topic: "vps-monitoring/{node_name}"
+      value_template: >
+        {{% set failed = (value_json.checks | default({{}})).failed_logins | default({{}}) %}}
+        {{{{ failed.result.total if failed.result is defined else none }}}}
+      icon: mdi:shield-alert
+
     - name: "Lookout: {node_name} Last Check Duration"
       state_topic: "vps-monitoring/{node_name}"
       value_template: "{{{{ value_json.check_duration | round(1) }}}}"